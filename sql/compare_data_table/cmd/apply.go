@@ -0,0 +1,626 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Decisions reviewers can enter in the Decision column of the report
+const (
+	decisionHeader      = "Decision"
+	decisionTakeDev     = "take dev"
+	decisionTakeStaging = "take staging"
+	decisionSkip        = "skip"
+)
+
+var decisionOptions = []string{decisionTakeDev, decisionTakeStaging, decisionSkip}
+
+// A reviewed decision read back from a report
+type reviewDecision struct {
+	sheet      string
+	row        int // workbook row, for messages
+	tableName  string
+	kind       string // "Diff", "OnlyInDev" or "OnlyInStaging"
+	decision   string
+	keyColumns []string
+	keyValues  []string // cell text of the key columns
+	column     string   // changed column, Diff sheets only
+}
+
+// A statement generated from the decisions, run against one environment
+type syncStatement struct {
+	target    string // "dev" or "staging"
+	action    string // "INSERT", "UPDATE" or "DELETE"
+	tableName string
+	where     string
+	columns   []string
+	values    []string // SQL literals
+}
+
+// Function to render a statement as SQL
+func (s syncStatement) SQL() string {
+	switch s.action {
+	case "INSERT":
+		quoted := make([]string, len(s.columns))
+		for i, col := range s.columns {
+			quoted[i] = quoteIdent(col)
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
+			quoteIdent(s.tableName), strings.Join(quoted, ", "), strings.Join(s.values, ", "))
+	case "UPDATE":
+		sets := make([]string, len(s.columns))
+		for i, col := range s.columns {
+			sets[i] = fmt.Sprintf("%s = %s", quoteIdent(col), s.values[i])
+		}
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", quoteIdent(s.tableName), strings.Join(sets, ", "), s.where)
+	default:
+		return fmt.Sprintf("DELETE FROM %s WHERE %s;", quoteIdent(s.tableName), s.where)
+	}
+}
+
+// Entry point of the "apply" subcommand: reads the decisions from a reviewed
+// report and generates, or applies, the SQL that carries them out
+func runApply(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	reportFlag := fs.String("report", "", "Reviewed comparison report (.xlsx) with filled-in Decision columns")
+	outputFlag := fs.String("output", "", "Prefix for the generated SQL files (default: auto-generated with timestamp)")
+	executeFlag := fs.Bool("execute", false, "Run the generated SQL against the databases instead of only writing it")
+	profiles := newProfileFlags(fs)
+	confirm := newConfirmer(fs)
+	fs.Parse(args)
+
+	if *reportFlag == "" {
+		log.Fatalf("Missing -report: the reviewed comparison report to apply")
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using system environment variables")
+	}
+
+	decisions, undecided, err := readReviewDecisions(*reportFlag)
+	if err != nil {
+		log.Fatalf("Failed to read decisions: %v", err)
+	}
+	log.Printf("Read %d decisions from %s (%d rows without a decision)", len(decisions), *reportFlag, undecided)
+
+	devConfig, stagingConfig, err := profiles.resolve()
+	if err != nil {
+		log.Fatalf("Failed to load database settings: %v", err)
+	}
+
+	log.Println("Connecting to development database...")
+	devDB, err := connectDB(devConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to development database: %v", err)
+	}
+
+	log.Println("Connecting to staging database...")
+	stagingDB, err := connectDB(stagingConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to staging database: %v", err)
+	}
+
+	statements, err := buildSyncStatements(decisions, map[string]*gorm.DB{"dev": devDB, "staging": stagingDB})
+	if err != nil {
+		log.Fatalf("Failed to generate SQL: %v", err)
+	}
+	if len(statements) == 0 {
+		log.Println("Nothing to apply: no row is marked 'take dev' or 'take staging'")
+		return
+	}
+
+	prefix := *outputFlag
+	if prefix == "" {
+		prefix = fmt.Sprintf("apply_%s", time.Now().Format("20060102_150405"))
+	}
+
+	targets := []applyTarget{
+		{"staging", stagingDB, stagingConfig},
+		{"dev", devDB, devConfig},
+	}
+
+	for _, target := range targets {
+		stmts := statementsFor(statements, target.name)
+		if len(stmts) == 0 {
+			continue
+		}
+		filename := fmt.Sprintf("%s_%s.sql", prefix, target.name)
+		if err := writeSyncScript(filename, *reportFlag, target.name, target.config, stmts); err != nil {
+			log.Fatalf("Failed to write SQL file: %v", err)
+		}
+		log.Printf("Wrote %d statements for %s to %s", len(stmts), target.name, filename)
+	}
+
+	if !*executeFlag {
+		log.Println("Review the SQL files, then run them or re-run with -execute")
+		return
+	}
+
+	// Ask for confirmation before changing any database
+	question := fmt.Sprintf("Apply %d statements to staging (%s) and %d statements to dev (%s)?",
+		len(statementsFor(statements, "staging")), stagingConfig,
+		len(statementsFor(statements, "dev")), devConfig)
+	if !confirm.confirm(question, true) {
+		log.Println("Operation cancelled by user")
+		return
+	}
+
+	if err := applyStatements(targets, statements); err != nil {
+		log.Fatalf("Failed to apply changes: %v", err)
+	}
+}
+
+// An environment the apply subcommand writes to
+type applyTarget struct {
+	name   string
+	db     *gorm.DB
+	config DBConfig
+}
+
+// Function to run the statements of every target in its own transaction.
+// Nothing is committed until the statements succeeded on every target; as two
+// databases can't be committed atomically, a failing commit after that names
+// the targets that were already committed.
+func applyStatements(targets []applyTarget, statements []syncStatement) error {
+	type openTx struct {
+		target applyTarget
+		tx     *gorm.DB
+		count  int
+	}
+	var open []openTx
+	rollback := func() {
+		for _, o := range open {
+			o.tx.Rollback()
+		}
+	}
+
+	for _, target := range targets {
+		stmts := statementsFor(statements, target.name)
+		if len(stmts) == 0 {
+			continue
+		}
+		tx := target.db.Begin()
+		if tx.Error != nil {
+			rollback()
+			return fmt.Errorf("failed to start transaction on %s (nothing was committed): %w", target.name, tx.Error)
+		}
+		open = append(open, openTx{target, tx, len(stmts)})
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt.SQL()).Error; err != nil {
+				rollback()
+				return fmt.Errorf("%s: %s: %w (all changes rolled back, nothing was committed)", target.name, stmt.SQL(), err)
+			}
+		}
+	}
+
+	var committed []string
+	for i, o := range open {
+		if err := o.tx.Commit().Error; err != nil {
+			for _, rest := range open[i+1:] {
+				rest.tx.Rollback()
+			}
+			if len(committed) == 0 {
+				return fmt.Errorf("failed to commit %s (nothing was committed): %w", o.target.name, err)
+			}
+			return fmt.Errorf("failed to commit %s after %s was committed; apply the %s statements again: %w",
+				o.target.name, strings.Join(committed, " and "), o.target.name, err)
+		}
+		committed = append(committed, o.target.name)
+		log.Printf("Applied %d statements to %s", o.count, o.target.name)
+	}
+	return nil
+}
+
+// Function to read the decisions from the Diff and Only-in sheets of a
+// report. Rows without a decision are counted but not returned.
+func readReviewDecisions(path string) ([]reviewDecision, int, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open report %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Decisions can only be applied between two databases
+	runInfo, _ := f.GetRows(runInfoSheetName)
+	for _, row := range runInfo {
+		if len(row) > 1 && strings.HasSuffix(row[0], "Master-Data File") {
+			return nil, 0, fmt.Errorf("report compares against a master-data file (%s); only database-to-database reports can be applied", row[1])
+		}
+		if len(row) > 1 && (row[0] == "Dev Schema" || row[0] == "Staging Schema") {
+			return nil, 0, fmt.Errorf("report reads tables from schema %s; only reports on the public schema can be applied", row[1])
+		}
+		if len(row) > 1 && row[0] == "Mapping" {
+			return nil, 0, fmt.Errorf("report maps names between the environments (%s); reports with mappings can't be applied", row[1])
+		}
+		if len(row) > 1 && row[0] == "Masked Columns" {
+			return nil, 0, fmt.Errorf("report masks column values (%s); masked values can't be matched back to records, so masked reports can't be applied", row[1])
+		}
+		if len(row) > 0 && strings.HasPrefix(row[0], "Query: ") {
+			return nil, 0, fmt.Errorf("report compares query results (%s); only table comparisons can be applied", strings.TrimPrefix(row[0], "Query: "))
+		}
+	}
+
+	index, err := f.GetRows(indexSheetName)
+	if err != nil || len(index) == 0 {
+		return nil, 0, fmt.Errorf("report has no %s sheet", indexSheetName)
+	}
+
+	kinds := make(map[string]string)
+	for _, kind := range detailSheetKinds {
+		kinds[kind.label] = kind.suffix
+	}
+
+	// Decisions on value differences are taken on the Diff sheets; a report
+	// written with -diff-view=record only has the Records sheets
+	hasDiffSheet := make(map[string]bool)
+	for _, entry := range index[1:] {
+		if kinds[cellText(entry, 2)] == "Diff" {
+			hasDiffSheet[cellText(entry, 1)] = true
+		}
+	}
+	for _, entry := range index[1:] {
+		if tableName := cellText(entry, 1); kinds[cellText(entry, 2)] == "Records" && !hasDiffSheet[tableName] {
+			return nil, 0, fmt.Errorf("report was written with -diff-view=record, so value differences of %s have no Diff sheet to decide on; rerun the comparison with -diff-view=column or -diff-view=both", tableName)
+		}
+	}
+
+	var decisions []reviewDecision
+	undecided := 0
+	for _, entry := range index[1:] {
+		sheet, tableName, kind := cellText(entry, 0), cellText(entry, 1), kinds[cellText(entry, 2)]
+		if kind != "Diff" && kind != "OnlyInDev" && kind != "OnlyInStaging" {
+			continue
+		}
+
+		var keyColumns []string
+		for _, col := range strings.Split(cellText(entry, 4), ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				keyColumns = append(keyColumns, col)
+			}
+		}
+		if len(keyColumns) == 0 {
+			return nil, 0, fmt.Errorf("index has no key columns for sheet %s", sheet)
+		}
+
+		// Raw values, so numbers and dates come back unformatted
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+		}
+		if len(rows) < 2 || cellText(rows[1], 0) != decisionHeader {
+			return nil, 0, fmt.Errorf("sheet %s has no %s column", sheet, decisionHeader)
+		}
+
+		// Locate the key columns (and the changed column on Diff sheets)
+		header := rows[1]
+		keyPositions := make([]int, len(keyColumns))
+		columnPosition := -1
+		if kind == "Diff" {
+			// Decision, Primary Key, <key columns>, Column, Dev Value, Staging Value
+			for i := range keyColumns {
+				keyPositions[i] = 2 + i
+			}
+			columnPosition = 2 + len(keyColumns)
+		} else {
+			for i, col := range keyColumns {
+				keyPositions[i] = -1
+				for pos, name := range header {
+					if pos > 0 && name == col {
+						keyPositions[i] = pos
+					}
+				}
+				if keyPositions[i] < 0 {
+					return nil, 0, fmt.Errorf("sheet %s has no key column %s", sheet, col)
+				}
+			}
+		}
+
+		for rowIdx, row := range rows[2:] {
+			decision := strings.ToLower(strings.Join(strings.Fields(cellText(row, 0)), " "))
+			if decision == "" {
+				undecided++
+				continue
+			}
+			if decision != decisionTakeDev && decision != decisionTakeStaging && decision != decisionSkip {
+				return nil, 0, fmt.Errorf("sheet %s row %d: unknown decision %q (expected %s)",
+					sheet, rowIdx+3, cellText(row, 0), strings.Join(decisionOptions, ", "))
+			}
+
+			d := reviewDecision{
+				sheet:      sheet,
+				row:        rowIdx + 3,
+				tableName:  tableName,
+				kind:       kind,
+				decision:   decision,
+				keyColumns: keyColumns,
+			}
+			for _, pos := range keyPositions {
+				d.keyValues = append(d.keyValues, cellText(row, pos))
+			}
+			if columnPosition >= 0 {
+				d.column = cellText(row, columnPosition)
+			}
+			decisions = append(decisions, d)
+		}
+	}
+
+	return decisions, undecided, nil
+}
+
+// Helper function to get a cell of a row read with GetRows, which drops
+// trailing empty cells
+func cellText(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// Function to turn decisions into SQL statements. Row values are read from
+// the databases at this point, not from the report, so the SQL reflects the
+// current data; rows that changed since the report was made are skipped
+// with a warning.
+func buildSyncStatements(decisions []reviewDecision, dbs map[string]*gorm.DB) ([]syncStatement, error) {
+	columnCache := make(map[string][]tableColumn)
+	getColumns := func(env, tableName string) ([]tableColumn, error) {
+		cacheKey := env + "." + tableName
+		if cols, ok := columnCache[cacheKey]; ok {
+			return cols, nil
+		}
+		cols, err := getTableColumns(dbs[env], tableName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get columns of %s table %s: %w", env, tableName, err)
+		}
+		columnCache[cacheKey] = cols
+		return cols, nil
+	}
+
+	var statements []syncStatement
+	updates := make(map[string]int) // target|table|where -> statement index, to merge column updates
+
+	for _, d := range decisions {
+		if d.decision == decisionSkip {
+			continue
+		}
+
+		// The chosen side is the source; the other side is changed to match it
+		source, target := "dev", "staging"
+		if d.decision == decisionTakeStaging {
+			source, target = "staging", "dev"
+		}
+
+		sourceColumns, err := getColumns(source, d.tableName)
+		if err != nil {
+			return nil, err
+		}
+		targetColumns, err := getColumns(target, d.tableName)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceRow, err := fetchRowByKey(dbs[source], d, sourceColumns)
+		if err != nil {
+			return nil, err
+		}
+		targetRow, err := fetchRowByKey(dbs[target], d, targetColumns)
+		if err != nil {
+			return nil, err
+		}
+
+		location := fmt.Sprintf("%s row %d", d.sheet, d.row)
+		switch {
+		case d.kind == "Diff":
+			if sourceRow == nil || targetRow == nil {
+				log.Printf("Warning: %s: record no longer exists in both environments - skipping", location)
+				continue
+			}
+			if _, ok := sourceRow[d.column]; !ok {
+				log.Printf("Warning: %s: column %s not found in %s - skipping", location, d.column, source)
+				continue
+			}
+			if _, ok := targetRow[d.column]; !ok {
+				log.Printf("Warning: %s: column %s not found in %s - skipping", location, d.column, target)
+				continue
+			}
+
+			where := keyCondition(d.keyColumns, targetRow)
+			mergeKey := target + "|" + d.tableName + "|" + where
+			if idx, ok := updates[mergeKey]; ok {
+				if !containsString(statements[idx].columns, d.column) {
+					statements[idx].columns = append(statements[idx].columns, d.column)
+					statements[idx].values = append(statements[idx].values, sqlLiteral(sourceRow[d.column]))
+				}
+				continue
+			}
+			updates[mergeKey] = len(statements)
+			statements = append(statements, syncStatement{
+				target:    target,
+				action:    "UPDATE",
+				tableName: d.tableName,
+				where:     where,
+				columns:   []string{d.column},
+				values:    []string{sqlLiteral(sourceRow[d.column])},
+			})
+
+		case (d.kind == "OnlyInDev") == (source == "dev"):
+			// The row exists only on the chosen side: copy it over
+			if sourceRow == nil {
+				log.Printf("Warning: %s: record no longer exists in %s - skipping", location, source)
+				continue
+			}
+			if targetRow != nil {
+				log.Printf("Warning: %s: record already exists in %s - skipping", location, target)
+				continue
+			}
+			stmt := syncStatement{target: target, action: "INSERT", tableName: d.tableName}
+			for _, col := range sourceColumns {
+				if !hasTableColumn(targetColumns, col.Name) {
+					continue
+				}
+				stmt.columns = append(stmt.columns, col.Name)
+				stmt.values = append(stmt.values, sqlLiteral(sourceRow[col.Name]))
+			}
+			statements = append(statements, stmt)
+
+		default:
+			// The row is missing on the chosen side: remove it from the other
+			if targetRow == nil {
+				log.Printf("Warning: %s: record no longer exists in %s - skipping", location, target)
+				continue
+			}
+			statements = append(statements, syncStatement{
+				target:    target,
+				action:    "DELETE",
+				tableName: d.tableName,
+				where:     keyCondition(d.keyColumns, targetRow),
+			})
+		}
+	}
+
+	return statements, nil
+}
+
+// A writable column of a table
+type tableColumn struct {
+	Name     string
+	DataType string
+}
+
+// Function to get the writable columns of a table in table order
+func getTableColumns(db *gorm.DB, tableName string) ([]tableColumn, error) {
+	var columns []tableColumn
+	err := db.Raw(`
+		SELECT column_name AS name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = 'public'
+		AND table_name = ?
+		AND is_generated = 'NEVER'
+		ORDER BY ordinal_position
+	`, tableName).Scan(&columns).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table not found")
+	}
+	return columns, nil
+}
+
+// Helper function to check whether a table has a column
+func hasTableColumn(columns []tableColumn, name string) bool {
+	for _, col := range columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to fetch the row a decision refers to, with every column as text
+// so the values can be written back exactly. Returns nil when the row doesn't
+// exist.
+func fetchRowByKey(db *gorm.DB, d reviewDecision, columns []tableColumn) (map[string]interface{}, error) {
+	selects := make([]string, len(columns))
+	types := make(map[string]string)
+	for i, col := range columns {
+		selects[i] = fmt.Sprintf("%s::text AS %s", quoteIdent(col.Name), quoteIdent(col.Name))
+		types[col.Name] = col.DataType
+	}
+
+	var conditions []string
+	var args []interface{}
+	for i, col := range d.keyColumns {
+		// Key cells hold the value as text; convert it back using the column type
+		val, err := coerceFileValue(d.keyValues[i], types[col])
+		if err != nil {
+			return nil, fmt.Errorf("%s row %d, key column %s: %w", d.sheet, d.row, col, err)
+		}
+		if val == nil {
+			conditions = append(conditions, fmt.Sprintf("%s IS NULL", quoteIdent(col)))
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdent(col)))
+		args = append(args, val)
+	}
+
+	var rows []map[string]interface{}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(selects, ", "), quoteIdent(d.tableName), strings.Join(conditions, " AND "))
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("%s row %d: failed to fetch record: %w", d.sheet, d.row, err)
+	}
+
+	switch len(rows) {
+	case 0:
+		return nil, nil
+	case 1:
+		return rows[0], nil
+	default:
+		return nil, fmt.Errorf("%s row %d: key matches %d records in table %s", d.sheet, d.row, len(rows), d.tableName)
+	}
+}
+
+// Helper function to build a WHERE condition matching a row's key columns
+func keyCondition(keyColumns []string, row map[string]interface{}) string {
+	conditions := make([]string, len(keyColumns))
+	for i, col := range keyColumns {
+		if row[col] == nil {
+			conditions[i] = fmt.Sprintf("%s IS NULL", quoteIdent(col))
+		} else {
+			conditions[i] = fmt.Sprintf("%s = %s", quoteIdent(col), sqlLiteral(row[col]))
+		}
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// Helper function to quote an SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Helper function to write a value fetched as text as an SQL literal. The
+// untyped literal is converted to the column type by PostgreSQL.
+func sqlLiteral(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return "'" + strings.ReplaceAll(string(v), "'", "''") + "'"
+	default:
+		return "'" + strings.ReplaceAll(fmt.Sprintf("%v", v), "'", "''") + "'"
+	}
+}
+
+// Helper function to select the statements for one environment
+func statementsFor(statements []syncStatement, target string) []syncStatement {
+	var selected []syncStatement
+	for _, stmt := range statements {
+		if stmt.target == target {
+			selected = append(selected, stmt)
+		}
+	}
+	return selected
+}
+
+// Function to write the statements for one environment as a SQL script
+// wrapped in a transaction
+func writeSyncScript(filename, report, target string, config DBConfig, statements []syncStatement) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Generated from %s on %s\n", report, time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "-- Target: %s (%s)\n\n", target, config)
+	b.WriteString("BEGIN;\n\n")
+	for _, stmt := range statements {
+		b.WriteString(stmt.SQL())
+		b.WriteString("\n")
+	}
+	b.WriteString("\nCOMMIT;\n")
+
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestSyncStatementSQL(t *testing.T) {
+	tests := []struct {
+		stmt syncStatement
+		want string
+	}{
+		{
+			syncStatement{action: "INSERT", tableName: "users", columns: []string{"id", "name"}, values: []string{"'1'", "'O''Brien'"}},
+			`INSERT INTO "users" ("id", "name") VALUES ('1', 'O''Brien');`,
+		},
+		{
+			syncStatement{action: "UPDATE", tableName: "users", where: `"id" = '1'`, columns: []string{"name", "email"}, values: []string{"'a'", "NULL"}},
+			`UPDATE "users" SET "name" = 'a', "email" = NULL WHERE "id" = '1';`,
+		},
+		{
+			syncStatement{action: "DELETE", tableName: `odd"name`, where: `"id" = '1'`},
+			`DELETE FROM "odd""name" WHERE "id" = '1';`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.stmt.SQL(); got != tt.want {
+			t.Errorf("%s SQL() = %q, want %q", tt.stmt.action, got, tt.want)
+		}
+	}
+}
+
+func TestKeyCondition(t *testing.T) {
+	tests := []struct {
+		keys []string
+		row  map[string]interface{}
+		want string
+	}{
+		{[]string{"id"}, map[string]interface{}{"id": "42"}, `"id" = '42'`},
+		{[]string{"tenant", "code"}, map[string]interface{}{"tenant": "a'b", "code": nil}, `"tenant" = 'a''b' AND "code" IS NULL`},
+		{[]string{"raw"}, map[string]interface{}{"raw": []byte("x")}, `"raw" = 'x'`},
+	}
+
+	for _, tt := range tests {
+		if got := keyCondition(tt.keys, tt.row); got != tt.want {
+			t.Errorf("keyCondition(%v, %v) = %q, want %q", tt.keys, tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestStatementsFor(t *testing.T) {
+	statements := []syncStatement{
+		{target: "dev", action: "DELETE"},
+		{target: "staging", action: "INSERT"},
+		{target: "dev", action: "UPDATE"},
+	}
+
+	got := statementsFor(statements, "dev")
+	if len(got) != 2 || got[0].action != "DELETE" || got[1].action != "UPDATE" {
+		t.Errorf("statementsFor(dev) = %+v, want the DELETE and UPDATE in order", got)
+	}
+	if got := statementsFor(statements, "other"); got != nil {
+		t.Errorf("statementsFor(other) = %+v, want none", got)
+	}
+}
+
+func TestReadReviewDecisionsKeepsKeyPrecision(t *testing.T) {
+	created := time.Date(2024, 3, 5, 10, 30, 0, 123456000, time.UTC)
+	result := map[string]interface{}{
+		"table_name":      "events",
+		"columns":         []string{"id", "created", "name"},
+		"column_types":    map[string]string{"id": "bigint", "created": "timestamp with time zone", "name": "text"},
+		"primary_keys":    []string{"id", "created"},
+		"has_primary_key": true,
+		"using_composite": true,
+		"differences": []map[string]interface{}{{
+			"key": "9007199254740993|2024-03-05 10:30:00.123456 +0000 UTC", "pk_id": int64(9007199254740993), "pk_created": created,
+			"column": "name", "dev_value": "a", "staging_value": "b",
+		}},
+		"only_in_dev": []map[string]interface{}{{
+			"id": int64(7), "created": created, "name": "c",
+		}},
+		"only_in_staging": []map[string]interface{}{},
+		"dev_count":       int64(2),
+		"staging_count":   int64(1),
+		"count_diff":      int64(1),
+		"matched_rows":    1,
+	}
+
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	if err := exportToExcel([]map[string]interface{}{result}, path, ExportOptions{}); err != nil {
+		t.Fatalf("exportToExcel: %v", err)
+	}
+
+	// Decide on every review sheet, as a reviewer would
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sheet := range f.GetSheetList() {
+		if header, _ := f.GetCellValue(sheet, "A2"); header == decisionHeader {
+			if err := f.SetCellValue(sheet, "A3", " Take  Dev "); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	decisions, undecided, err := readReviewDecisions(path)
+	if err != nil {
+		t.Fatalf("readReviewDecisions: %v", err)
+	}
+	if undecided != 0 || len(decisions) != 2 {
+		t.Fatalf("readReviewDecisions = %d decisions, %d undecided, want 2 and 0", len(decisions), undecided)
+	}
+
+	wantKeys := map[string][]string{
+		"Diff":      {"9007199254740993", "2024-03-05T10:30:00.123456Z"},
+		"OnlyInDev": {"7", "2024-03-05T10:30:00.123456Z"},
+	}
+	for _, d := range decisions {
+		if d.decision != decisionTakeDev {
+			t.Errorf("%s decision = %q, want %q", d.kind, d.decision, decisionTakeDev)
+		}
+		if !reflect.DeepEqual(d.keyValues, wantKeys[d.kind]) {
+			t.Errorf("%s key values = %q, want %q", d.kind, d.keyValues, wantKeys[d.kind])
+		}
+		if d.kind == "Diff" && d.column != "name" {
+			t.Errorf("Diff column = %q, want name", d.column)
+		}
+	}
+
+	// The key text converts back to the exact value
+	if got, err := coerceFileValue(wantKeys["Diff"][1], "timestamp with time zone"); err != nil || !got.(time.Time).Equal(created) {
+		t.Errorf("key timestamp reads back as %v, %v, want %v", got, err, created)
+	}
+}
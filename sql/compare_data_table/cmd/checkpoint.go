@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default checkpoint file, written next to where the tool is run
+const defaultCheckpointFile = "data_comparison.checkpoint"
+
+func init() {
+	// Concrete types stored in comparison results, so results survive a
+	// round trip through the checkpoint file unchanged
+	gob.Register(map[string]interface{}{})
+	gob.Register([]map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register([]string{})
+	gob.Register(map[string]string{})
+	gob.Register([]byte{})
+	gob.Register(time.Time{})
+	gob.Register(time.Duration(0))
+	gob.Register([]valueTransition{})
+	gob.Register([]jsonPathDiff{})
+	gob.Register(jsonMissing{})
+}
+
+// jsonMissing has no fields, which gob can't encode on its own
+func (jsonMissing) GobEncode() ([]byte, error) { return []byte{}, nil }
+func (*jsonMissing) GobDecode([]byte) error    { return nil }
+
+// Progress of a comparison run, saved after every compared table so an
+// interrupted run can be resumed with -resume. The file holds a header and
+// one record per compared table, appended as each table finishes, so saving
+// a table never rewrites the tables before it.
+type checkpoint struct {
+	checkpointHeader
+	path    string
+	Results map[string]map[string]interface{} // table name -> result read from the file
+
+	mu   sync.Mutex
+	file *os.File // opened on the first save
+	size int64    // end of the last complete record of a resumed file, -1 for a new file
+}
+
+// Header at the start of a checkpoint file
+type checkpointHeader struct {
+	Dev        string // database (or master-data file) the results were read from
+	Staging    string
+	Settings   string // settings that shape the results, see resultSettings
+	SampleSalt string // salt of -sample-method=random, reused on resume so the same rows are sampled
+	CreatedAt  time.Time
+}
+
+// Record appended to a checkpoint file for every compared table
+type checkpointRecord struct {
+	Table  string
+	Result map[string]interface{}
+}
+
+// Function to start a new checkpoint for a run
+func newCheckpoint(path, dev, staging, settings string) *checkpoint {
+	return &checkpoint{
+		checkpointHeader: checkpointHeader{
+			Dev:       dev,
+			Staging:   staging,
+			Settings:  settings,
+			CreatedAt: time.Now(),
+		},
+		path:    path,
+		Results: make(map[string]map[string]interface{}),
+		size:    -1,
+	}
+}
+
+// Function to describe the settings that shape comparison results. Results
+// are only resumed by a run with the same settings.
+func resultSettings(mode string, opts CompareOptions, queries []namedQuery) string {
+	var lines []string
+	if mode != "" {
+		lines = append(lines, "mode "+mode)
+	}
+	for _, rule := range opts.Normalizers.describe() {
+		lines = append(lines, "normalize "+rule)
+	}
+	if sd := opts.SoftDelete; sd != nil {
+		lines = append(lines, fmt.Sprintf("soft-delete %s flags %s timestamps %s", sd.Mode, strings.Join(sd.FlagColumns, ","), strings.Join(sd.TimestampColumns, ",")))
+	}
+	for _, rule := range opts.Masks.describe() {
+		lines = append(lines, "mask "+rule)
+	}
+	if opts.Masks.hashes() {
+		lines = append(lines, "mask key "+opts.Masks.keyCheck())
+	}
+	for _, mapping := range opts.Mappings.describe() {
+		lines = append(lines, "mapping "+mapping)
+	}
+	for _, query := range queries {
+		lines = append(lines, fmt.Sprintf("query %s key %s: %s", query.Name, strings.Join(query.Key, ","), query.SQL))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Function to load a checkpoint to resume from. The checkpoint must have been
+// written for the same databases and with the same settings. New results are
+// appended to the loaded file.
+func loadCheckpoint(path, dev, staging, settings string) (*checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint %s: %w", path, err)
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	cp := &checkpoint{path: path, Results: make(map[string]map[string]interface{})}
+	n, err := readCheckpointRecord(r, &cp.checkpointHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+	cp.size = n
+
+	if cp.Dev != dev || cp.Staging != staging {
+		return nil, fmt.Errorf("checkpoint %s was written for dev %s and staging %s, not dev %s and staging %s",
+			path, cp.Dev, cp.Staging, dev, staging)
+	}
+	if cp.Settings != settings {
+		return nil, fmt.Errorf("checkpoint %s was written with other settings:\n%s\nthis run uses:\n%s",
+			path, cp.Settings, settings)
+	}
+
+	for {
+		var record checkpointRecord
+		n, err := readCheckpointRecord(r, &record)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			// The run stopped while a table was being saved; that table is
+			// compared again and its partial record overwritten
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+		}
+		cp.Results[record.Table] = record.Result
+		cp.size += n
+	}
+
+	return cp, nil
+}
+
+// Function to get the stored result of a table, if it was already compared.
+// The results are only read, so parallel workers need no lock.
+func (cp *checkpoint) result(tableName string) (map[string]interface{}, bool) {
+	if cp == nil {
+		return nil, false
+	}
+	result, ok := cp.Results[tableName]
+	return result, ok
+}
+
+// Function to append the result of a compared table to the checkpoint file.
+// The result is encoded before taking the lock, so workers only wait for each
+// other while writing.
+func (cp *checkpoint) save(result map[string]interface{}) error {
+	if cp == nil {
+		return nil
+	}
+	data, err := encodeCheckpointRecord(checkpointRecord{Table: result["table_name"].(string), Result: result})
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.file == nil {
+		if err := cp.open(); err != nil {
+			return fmt.Errorf("failed to create checkpoint: %w", err)
+		}
+	}
+	if _, err := cp.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Helper function to open the checkpoint file for appending: a new file
+// starts with the header, a resumed file loses a partial last record
+func (cp *checkpoint) open() error {
+	if cp.size >= 0 {
+		file, err := os.OpenFile(cp.path, os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		if err := file.Truncate(cp.size); err != nil {
+			file.Close()
+			return err
+		}
+		cp.file = file
+		return nil
+	}
+
+	header, err := encodeCheckpointRecord(cp.checkpointHeader)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(cp.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return err
+	}
+	cp.file = file
+	return nil
+}
+
+// Helper function to encode a checkpoint record: its length, then the record
+// as a self-contained gob stream
+func encodeCheckpointRecord(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 8))
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint64(data, uint64(len(data)-8))
+	return data, nil
+}
+
+// Helper function to read a record written by encodeCheckpointRecord.
+// Returns the number of bytes read, io.EOF at the end of the file and
+// io.ErrUnexpectedEOF for a record that was cut off.
+func readCheckpointRecord(r io.Reader, v interface{}) (int64, error) {
+	var length [8]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return 0, err
+	}
+	data := make([]byte, binary.BigEndian.Uint64(length[:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return 0, err
+	}
+	return int64(len(data) + 8), nil
+}
+
+// Function to delete the checkpoint once the run has completed
+func (cp *checkpoint) remove() error {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.file != nil {
+		cp.file.Close()
+		cp.file = nil
+	}
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
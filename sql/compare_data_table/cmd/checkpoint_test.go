@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+
+	cp := newCheckpoint(path, "dev:5432/app", "staging:5432/app", "normalize *: trim")
+	cp.SampleSalt = "5eed"
+
+	results := []map[string]interface{}{
+		{
+			"table_name":  "users",
+			"status":      "different",
+			"row_count":   int64(3),
+			"duration":    2 * time.Second,
+			"compared_at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			"columns":     []string{"id", "email"},
+			"differences": []map[string]interface{}{
+				{"column": "email", "dev_value": "a@b", "staging_value": nil, "raw": []byte("x")},
+			},
+			"json_diffs":      []jsonPathDiff{{Path: "$.a", DevValue: jsonMissing{}, StagingValue: "1"}},
+			"top_transitions": []valueTransition{{From: "a", To: "b", Count: 2}},
+			"key":             map[string]interface{}{"id": int64(7)},
+			"renamed":         map[string]string{"old": "new"},
+			"values":          []interface{}{"x", 1.5},
+		},
+		{"table_name": "orders", "status": "identical"},
+	}
+	for _, result := range results {
+		if err := cp.save(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := loadCheckpoint(path, "dev:5432/app", "staging:5432/app", "normalize *: trim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.CreatedAt.Equal(cp.CreatedAt) {
+		t.Errorf("loaded CreatedAt %v, want %v", loaded.CreatedAt, cp.CreatedAt)
+	}
+	if loaded.SampleSalt != cp.SampleSalt {
+		t.Errorf("loaded SampleSalt %q, want %q", loaded.SampleSalt, cp.SampleSalt)
+	}
+	for _, want := range results {
+		got, ok := loaded.result(want["table_name"].(string))
+		if !ok {
+			t.Errorf("result of %s missing after the round trip", want["table_name"])
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("result of %s = %#v, want %#v", want["table_name"], got, want)
+		}
+	}
+	if _, ok := loaded.result("missing"); ok {
+		t.Error("result of a table that was never saved was found")
+	}
+}
+
+func TestCheckpointResumeAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+
+	cp := newCheckpoint(path, "dev", "staging", "")
+	for _, table := range []string{"a", "b"} {
+		if err := cp.save(map[string]interface{}{"table_name": table}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cp.file.Close()
+
+	// A run stopped while saving leaves a partial record behind
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 0, 0, 0, 0, 1, 0, 'x'})
+	file.Close()
+
+	resumed, err := loadCheckpoint(path, "dev", "staging", "")
+	if err != nil {
+		t.Fatalf("loadCheckpoint with a partial record: %v", err)
+	}
+	if len(resumed.Results) != 2 {
+		t.Fatalf("resumed %d tables, want 2", len(resumed.Results))
+	}
+	if err := resumed.save(map[string]interface{}{"table_name": "c"}); err != nil {
+		t.Fatal(err)
+	}
+	resumed.file.Close()
+
+	again, err := loadCheckpoint(path, "dev", "staging", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"a", "b", "c"} {
+		if _, ok := again.result(table); !ok {
+			t.Errorf("result of %s missing after resuming twice", table)
+		}
+	}
+}
+
+func TestLoadCheckpointMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+	if err := newCheckpoint(path, "dev", "staging", "normalize *: trim").save(map[string]interface{}{"table_name": "users"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                   string
+		dev, staging, settings string
+		wantErr                bool
+	}{
+		{"same run", "dev", "staging", "normalize *: trim", false},
+		{"other dev", "dev2", "staging", "normalize *: trim", true},
+		{"other staging", "dev", "staging2", "normalize *: trim", true},
+		{"other settings", "dev", "staging", "normalize *: lower", true},
+	}
+
+	for _, tt := range tests {
+		_, err := loadCheckpoint(path, tt.dev, tt.staging, tt.settings)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: loadCheckpoint error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if _, err := loadCheckpoint(filepath.Join(t.TempDir(), "none"), "dev", "staging", ""); err == nil {
+		t.Error("loadCheckpoint of a missing file succeeded")
+	}
+}
+
+func TestCheckpointRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+	cp := newCheckpoint(path, "dev", "staging", "")
+	if err := cp.save(map[string]interface{}{"table_name": "users"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing twice is fine, and so is a run without checkpoint
+	for _, c := range []*checkpoint{cp, cp, nil} {
+		if err := c.remove(); err != nil {
+			t.Errorf("remove: %v", err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still exists after remove: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Master-table classification rules from the config file. Rules are checked
+// in this order and the first one that applies decides: exclude patterns,
+// table comments, the table list, include patterns, then the size and write
+// heuristics. Without this section the built-in naming rules are used.
+type MasterTableConfig struct {
+	Include    []string `yaml:"include"`     // regexes of master table names
+	Exclude    []string `yaml:"exclude"`     // regexes of tables that are never master data
+	Tables     []string `yaml:"tables"`      // exact master table names
+	CommentTag string   `yaml:"comment_tag"` // tag in COMMENT ON TABLE marking master data (default "@master")
+	Builtin    bool     `yaml:"builtin"`     // also apply the built-in naming rules
+
+	Heuristics *struct {
+		MaxRows       int64   `yaml:"max_rows"`        // at most this many live rows
+		MaxWriteRatio float64 `yaml:"max_write_ratio"` // at most this many writes per live row since the stats reset
+	} `yaml:"heuristics"`
+}
+
+// Default tag in a table comment marking a master table. "<tag>:false" (for
+// example "@master:false") marks a table that is not master data.
+const defaultMasterCommentTag = "@master"
+
+// What is known about a table when classifying it
+type tableInfo struct {
+	Name     string
+	Comment  string
+	LiveRows int64
+	Writes   int64 // inserts + updates + deletes since the statistics were reset
+}
+
+// The outcome of classifying a table, with the rule that decided it
+type tableClassification struct {
+	Name   string
+	Master bool
+	Reason string
+}
+
+// A rule deciding whether a table is master data. ok is false when the rule
+// doesn't apply to the table, so the next rule is asked.
+type tableClassifier interface {
+	classify(table tableInfo) (master bool, reason string, ok bool)
+}
+
+// Classifier matching table names against regexes
+type patternClassifier struct {
+	patterns []*regexp.Regexp
+	master   bool
+	label    string
+}
+
+func (c patternClassifier) classify(table tableInfo) (bool, string, bool) {
+	for _, re := range c.patterns {
+		if re.MatchString(table.Name) {
+			return c.master, fmt.Sprintf("%s %s", c.label, re), true
+		}
+	}
+	return false, "", false
+}
+
+// Classifier using a list of table names
+type listClassifier struct {
+	tables map[string]bool
+	label  string
+}
+
+func (c listClassifier) classify(table tableInfo) (bool, string, bool) {
+	if c.tables[table.Name] {
+		return true, c.label, true
+	}
+	return false, "", false
+}
+
+// Classifier reading a tag from the table comment
+type commentClassifier struct {
+	tag string
+}
+
+func (c commentClassifier) classify(table tableInfo) (bool, string, bool) {
+	for _, word := range strings.Fields(table.Comment) {
+		switch {
+		case word == c.tag || word == c.tag+":true":
+			return true, fmt.Sprintf("table comment has %s", word), true
+		case word == c.tag+":false":
+			return false, fmt.Sprintf("table comment has %s", word), true
+		}
+	}
+	return false, "", false
+}
+
+// Classifier treating small, rarely written tables as master data, based on
+// pg_stat_user_tables
+type heuristicClassifier struct {
+	maxRows       int64
+	maxWriteRatio float64
+}
+
+func (c heuristicClassifier) classify(table tableInfo) (bool, string, bool) {
+	if table.LiveRows > c.maxRows {
+		return false, "", false
+	}
+
+	rows := table.LiveRows
+	if rows == 0 {
+		rows = 1
+	}
+	ratio := float64(table.Writes) / float64(rows)
+	if c.maxWriteRatio > 0 && ratio > c.maxWriteRatio {
+		return false, "", false
+	}
+
+	return true, fmt.Sprintf("heuristic: %d rows, %.2f writes per row", table.LiveRows, ratio), true
+}
+
+// Built-in naming rules used when no classification is configured
+var builtinMasterTables = []string{
+	"categories", "products", "customers", "suppliers",
+	"regions", "countries", "departments", "currencies",
+	"users", "roles", "permissions",
+}
+
+func builtinClassifiers() []tableClassifier {
+	tables := make(map[string]bool)
+	for _, name := range builtinMasterTables {
+		tables[name] = true
+	}
+	return []tableClassifier{
+		patternClassifier{patterns: []*regexp.Regexp{regexp.MustCompile(`master`), regexp.MustCompile(`^m_`)}, master: true, label: "built-in name pattern"},
+		listClassifier{tables: tables, label: "built-in master table list"},
+	}
+}
+
+// Function to build the classifier chain from the config (nil gives the
+// built-in rules)
+func newTableClassifiers(config *MasterTableConfig) ([]tableClassifier, error) {
+	if config == nil {
+		return append([]tableClassifier{commentClassifier{tag: defaultMasterCommentTag}}, builtinClassifiers()...), nil
+	}
+
+	compile := func(patterns []string) ([]*regexp.Regexp, error) {
+		var compiled []*regexp.Regexp
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid master table pattern %q: %w", pattern, err)
+			}
+			compiled = append(compiled, re)
+		}
+		return compiled, nil
+	}
+
+	var classifiers []tableClassifier
+
+	exclude, err := compile(config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	if len(exclude) > 0 {
+		classifiers = append(classifiers, patternClassifier{patterns: exclude, master: false, label: "matches exclude pattern"})
+	}
+
+	tag := config.CommentTag
+	if tag == "" {
+		tag = defaultMasterCommentTag
+	}
+	classifiers = append(classifiers, commentClassifier{tag: tag})
+
+	if len(config.Tables) > 0 {
+		tables := make(map[string]bool)
+		for _, name := range config.Tables {
+			tables[name] = true
+		}
+		classifiers = append(classifiers, listClassifier{tables: tables, label: "listed in config"})
+	}
+
+	include, err := compile(config.Include)
+	if err != nil {
+		return nil, err
+	}
+	if len(include) > 0 {
+		classifiers = append(classifiers, patternClassifier{patterns: include, master: true, label: "matches include pattern"})
+	}
+
+	if config.Builtin {
+		classifiers = append(classifiers, builtinClassifiers()...)
+	}
+
+	if h := config.Heuristics; h != nil && h.MaxRows > 0 {
+		classifiers = append(classifiers, heuristicClassifier{maxRows: h.MaxRows, maxWriteRatio: h.MaxWriteRatio})
+	}
+
+	return classifiers, nil
+}
+
+// Function to classify every table in a schema of the database
+func classifyTables(db *gorm.DB, schema string, classifiers []tableClassifier) ([]tableClassification, error) {
+	var tables []tableInfo
+	err := db.Raw(`
+		SELECT c.relname AS name,
+			COALESCE(obj_description(c.oid, 'pg_class'), '') AS comment,
+			COALESCE(s.n_live_tup, 0) AS live_rows,
+			COALESCE(s.n_tup_ins + s.n_tup_upd + s.n_tup_del, 0) AS writes
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+		WHERE n.nspname = ?
+		AND c.relkind IN ('r', 'p')
+		AND NOT c.relispartition
+		ORDER BY c.relname
+	`, schema).Scan(&tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
+
+	classifications := make([]tableClassification, len(tables))
+	for i, table := range tables {
+		classifications[i] = tableClassification{Name: table.Name, Reason: "no rule matched"}
+		for _, classifier := range classifiers {
+			if master, reason, ok := classifier.classify(table); ok {
+				classifications[i].Master = master
+				classifications[i].Reason = reason
+				break
+			}
+		}
+	}
+
+	return classifications, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Comparison rules loaded from the -config YAML file
+type CompareConfig struct {
+	// Normalizers applied before comparison, keyed by "column" or "table.column" pattern
+	Normalize map[string][]string `yaml:"normalize"`
+
+	// Rules deciding which tables are master data (default: built-in naming rules)
+	MasterTables *MasterTableConfig `yaml:"master_tables"`
+
+	// Table and column renames between dev and staging
+	Mappings *MappingConfig `yaml:"mappings"`
+
+	// Soft-delete columns and how soft-deleted rows are treated
+	SoftDelete *SoftDeleteConfig `yaml:"soft_delete"`
+
+	// Sensitive columns masked in the outputs, keyed by "column" or "table.column" pattern
+	Mask map[string]string `yaml:"mask"`
+}
+
+// Function to load the comparison config file (an empty path gives an empty config)
+func loadCompareConfig(path string) (*CompareConfig, error) {
+	config := &CompareConfig{}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
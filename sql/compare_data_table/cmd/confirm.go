@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Answers confirmation prompts. Every step that asks before continuing goes
+// through a confirmer, so -yes and -non-interactive behave the same everywhere.
+type confirmer struct {
+	assumeYes      *bool // -yes: answer yes to every prompt
+	nonInteractive *bool // -non-interactive: never prompt (also when stdin is not a terminal)
+}
+
+// Function to create a confirmer, registering its -yes and -non-interactive
+// flags on a flag set
+func newConfirmer(fs *flag.FlagSet) *confirmer {
+	return &confirmer{
+		assumeYes:      fs.Bool("yes", false, "Answer yes to every confirmation prompt"),
+		nonInteractive: fs.Bool("non-interactive", false, "Never prompt: continue with read-only steps, refuse changes unless -yes is given (automatic when stdin is not a terminal)"),
+	}
+}
+
+// Helper function to check whether stdin is an interactive terminal
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Function to ask a yes/no question. Without a terminal, read-only steps
+// continue and steps that change data (destructive) are refused unless -yes
+// was given.
+func (c *confirmer) confirm(question string, destructive bool) bool {
+	if *c.assumeYes {
+		log.Printf("%s yes (-yes)", question)
+		return true
+	}
+
+	if *c.nonInteractive || !stdinIsTerminal() {
+		if destructive {
+			log.Printf("%s no (non-interactive, use -yes to confirm)", question)
+			return false
+		}
+		log.Printf("%s yes (non-interactive)", question)
+		return true
+	}
+
+	fmt.Printf("%s (y/n): ", question)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Longest line of a definition shown in the details of a difference
+const maxDefinitionLineLength = 80
+
+// A named definition read from the catalog
+type definitionInfo struct {
+	Name       string
+	Kind       string
+	Definition string
+}
+
+// Function to read views (relkind 'v') or materialized views (relkind 'm')
+// of a schema with their query. Here and for functions, objects created by an
+// extension are left out; they are compared through the extension version.
+func loadRelationDefinitions(ctx context.Context, db *gorm.DB, schema, relkind, kind string) ([]dbObject, error) {
+	var views []definitionInfo
+	err := db.WithContext(ctx).Raw(`
+		SELECT c.relname AS name, pg_get_viewdef(c.oid, true) AS definition
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = ?
+		AND c.relkind = ?
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend d
+			WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'e'
+		)
+		ORDER BY c.relname
+	`, schema, relkind).Scan(&views).Error
+	if err != nil {
+		return nil, err
+	}
+
+	qualifier := schemaQualifier(schema)
+	objects := make([]dbObject, 0, len(views))
+	for _, view := range views {
+		objects = append(objects, dbObject{
+			Kind:       kind,
+			Name:       view.Name,
+			Attributes: []objectAttribute{{"definition", normalizeDefinition(view.Definition, qualifier)}},
+		})
+	}
+	return objects, nil
+}
+
+func loadViews(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	return loadRelationDefinitions(ctx, db, schema, "v", "view")
+}
+
+func loadMaterializedViews(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	return loadRelationDefinitions(ctx, db, schema, "m", "matview")
+}
+
+// Function to read the functions and procedures of a schema. They are named
+// with their argument types, so overloads are compared separately.
+// Aggregates and window functions have no pg_get_functiondef and are skipped.
+func loadFunctions(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	var functions []definitionInfo
+	err := db.WithContext(ctx).Raw(`
+		SELECT p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')' AS name,
+			CASE p.prokind WHEN 'p' THEN 'procedure' ELSE 'function' END AS kind,
+			pg_get_functiondef(p.oid) AS definition
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = ?
+		AND p.prokind IN ('f', 'p')
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend d
+			WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e'
+		)
+		ORDER BY 1
+	`, schema).Scan(&functions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	qualifier := schemaQualifier(schema)
+	objects := make([]dbObject, 0, len(functions))
+	for _, function := range functions {
+		objects = append(objects, dbObject{
+			Kind:       function.Kind,
+			Name:       function.Name,
+			Attributes: []objectAttribute{{"definition", normalizeDefinition(function.Definition, qualifier)}},
+		})
+	}
+	return objects, nil
+}
+
+// Function to read the user-defined triggers on the tables of a schema,
+// named "table.trigger"
+func loadTriggers(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	var triggers []struct {
+		Name       string
+		Definition string
+		Enabled    string
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT c.relname || '.' || t.tgname AS name,
+			pg_get_triggerdef(t.oid, true) AS definition,
+			t.tgenabled::text AS enabled
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = ?
+		AND NOT t.tgisinternal
+		ORDER BY 1
+	`, schema).Scan(&triggers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	qualifier := schemaQualifier(schema)
+	objects := make([]dbObject, 0, len(triggers))
+	for _, trigger := range triggers {
+		objects = append(objects, dbObject{
+			Kind: "trigger",
+			Name: trigger.Name,
+			Attributes: []objectAttribute{
+				{"definition", normalizeDefinition(trigger.Definition, qualifier)},
+				{"enabled", triggerEnabled(trigger.Enabled)},
+			},
+		})
+	}
+	return objects, nil
+}
+
+// Function to describe pg_trigger.tgenabled
+func triggerEnabled(code string) string {
+	switch code {
+	case "O":
+		return "enabled"
+	case "D":
+		return "disabled"
+	case "R":
+		return "replica only"
+	case "A":
+		return "always"
+	}
+	return code
+}
+
+// Function to read the row-level security policies of a schema, named
+// "table.policy". Tables with row-level security enabled are listed as
+// "row security" objects, so enabling it on one side only shows up too.
+func loadPolicies(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	db = db.WithContext(ctx)
+
+	var policies []struct {
+		Name       string
+		Permissive string
+		Roles      string
+		Command    string
+		UsingExpr  *string
+		WithCheck  *string
+	}
+	err := db.Raw(`
+		SELECT tablename || '.' || policyname AS name,
+			permissive,
+			array_to_string(roles, ', ') AS roles,
+			cmd AS command,
+			qual AS using_expr,
+			with_check
+		FROM pg_policies
+		WHERE schemaname = ?
+		ORDER BY 1
+	`, schema).Scan(&policies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []struct {
+		Name   string
+		Forced bool
+	}
+	err = db.Raw(`
+		SELECT c.relname AS name, c.relforcerowsecurity AS forced
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = ?
+		AND c.relrowsecurity
+		ORDER BY c.relname
+	`, schema).Scan(&tables).Error
+	if err != nil {
+		return nil, err
+	}
+
+	qualifier := schemaQualifier(schema)
+	objects := make([]dbObject, 0, len(tables)+len(policies))
+	for _, table := range tables {
+		objects = append(objects, dbObject{
+			Kind:       "row security",
+			Name:       table.Name,
+			Attributes: []objectAttribute{{"forced", fmt.Sprintf("%t", table.Forced)}},
+		})
+	}
+	for _, policy := range policies {
+		using, withCheck := "", ""
+		if policy.UsingExpr != nil {
+			using = normalizeDefinition(*policy.UsingExpr, qualifier)
+		}
+		if policy.WithCheck != nil {
+			withCheck = normalizeDefinition(*policy.WithCheck, qualifier)
+		}
+		objects = append(objects, dbObject{
+			Kind: "policy",
+			Name: policy.Name,
+			Attributes: []objectAttribute{
+				{"permissive", policy.Permissive},
+				{"roles", policy.Roles},
+				{"command", policy.Command},
+				{"using", using},
+				{"with check", withCheck},
+			},
+		})
+	}
+	return objects, nil
+}
+
+// Function to read the installed extensions. Extensions belong to the
+// database rather than a schema, so the schema only names where each one is
+// installed.
+func loadExtensions(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	var extensions []struct {
+		Name    string
+		Version string
+		Schema  string
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT e.extname AS name, e.extversion AS version, n.nspname AS schema
+		FROM pg_extension e
+		JOIN pg_namespace n ON n.oid = e.extnamespace
+		ORDER BY e.extname
+	`).Scan(&extensions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]dbObject, 0, len(extensions))
+	for _, extension := range extensions {
+		objects = append(objects, dbObject{
+			Kind: "extension",
+			Name: extension.Name,
+			Attributes: []objectAttribute{
+				{"version", extension.Version},
+				{"schema", extension.Schema},
+			},
+		})
+	}
+	return objects, nil
+}
+
+var horizontalSpace = regexp.MustCompile(`[ \t]+`)
+
+// Function to build the pattern matching a schema qualifier ("schema." or
+// "\"schema\"." not preceded by another name), compiled once per schema by
+// the loaders
+func schemaQualifier(schema string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[^\w".])("` + regexp.QuoteMeta(schema) + `"|` + regexp.QuoteMeta(schema) + `)\.`)
+}
+
+// Function to normalize a deparsed definition so only meaningful changes
+// differ: line endings, indentation, repeated spaces and blank lines are
+// ignored, and the object's own schema qualifier is removed so objects in
+// differently named schemas can be compared.
+func normalizeDefinition(definition string, qualifier *regexp.Regexp) string {
+	definition = qualifier.ReplaceAllString(definition, "$1")
+
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(definition, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(horizontalSpace.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Function to explain a difference in a normalized definition by its first
+// differing line
+func explainDefinition(attribute, dev, staging string) string {
+	devLines, stagingLines := strings.Split(dev, "\n"), strings.Split(staging, "\n")
+	for i := 0; i < len(devLines) || i < len(stagingLines); i++ {
+		devLine, stagingLine := "(end)", "(end)"
+		if i < len(devLines) {
+			devLine = devLines[i]
+		}
+		if i < len(stagingLines) {
+			stagingLine = stagingLines[i]
+		}
+		if devLine != stagingLine {
+			return fmt.Sprintf("%s line %d: dev %s, staging %s", attribute, i+1, shortenLine(devLine), shortenLine(stagingLine))
+		}
+	}
+	return ""
+}
+
+// Function to shorten a definition line for the details of a difference
+func shortenLine(line string) string {
+	if runes := []rune(line); len(runes) > maxDefinitionLineLength {
+		return string(runes[:maxDefinitionLineLength-1]) + "…"
+	}
+	return line
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDefinition(t *testing.T) {
+	tests := []struct {
+		schema, definition, want string
+	}{
+		{"app", " SELECT  a,\tb\r\n\r\n   FROM app.users;", "SELECT a, b\nFROM users;"},
+		{"app", `SELECT * FROM "app".users JOIN app.orders USING (id)`, "SELECT * FROM users JOIN orders USING (id)"},
+		{"app", "SELECT myapp.f(), u.app.x FROM other.t", "SELECT myapp.f(), u.app.x FROM other.t"},
+		{"my.schema", `SELECT 1 FROM "my.schema".t, myxschema.t`, "SELECT 1 FROM t, myxschema.t"},
+		{"app", "(app.f(x) > 0)", "(f(x) > 0)"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeDefinition(tt.definition, schemaQualifier(tt.schema)); got != tt.want {
+			t.Errorf("normalizeDefinition(%q) in %s = %q, want %q", tt.definition, tt.schema, got, tt.want)
+		}
+	}
+}
+
+func TestExplainDefinition(t *testing.T) {
+	long := strings.Repeat("y", maxDefinitionLineLength+10)
+
+	tests := []struct {
+		dev, staging, want string
+	}{
+		{"a\nb", "a\nb", ""},
+		{"a\nb", "a\nc", "definition line 2: dev b, staging c"},
+		{"a", "a\nb", "definition line 2: dev (end), staging b"},
+		{"x", long, "definition line 1: dev x, staging " + strings.Repeat("y", maxDefinitionLineLength-1) + "…"},
+	}
+
+	for _, tt := range tests {
+		if got := explainDefinition("definition", tt.dev, tt.staging); got != tt.want {
+			t.Errorf("explainDefinition(%q, %q) = %q, want %q", tt.dev, tt.staging, got, tt.want)
+		}
+	}
+}
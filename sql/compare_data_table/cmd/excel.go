@@ -0,0 +1,1054 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Options that control the layout of the Excel report
+type ExportOptions struct {
+	// DiffView selects how value differences are shown: "column" (one row per
+	// changed column), "record" (one row per changed record) or "both"
+	DiffView string
+
+	// RunInfo describes the run for the "Run Info" sheet (optional)
+	RunInfo *RunInfo
+
+	// Kinds of database objects compared with -objects, and their differences
+	// for the "Objects" sheet (written when any kind was compared)
+	ObjectKinds []string
+	Objects     []objectDiff
+}
+
+// Styles shared by all sheets of the report
+type reportStyles struct {
+	header       int
+	diff         int
+	decision     int
+	notCompared  int
+	percent      int
+	link         int
+	date         int
+	dateDiff     int
+	dateTime     int
+	dateTimeDiff int
+	duration     int
+
+	// Differential styles used by conditional formatting
+	condWarning int
+	condError   int
+}
+
+// Function to register the report styles once per workbook
+func newReportStyles(f *excelize.File) (reportStyles, error) {
+	var styles reportStyles
+	var err error
+
+	styles.header, err = f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#DDEBF7"}, Pattern: 1},
+		Border: []excelize.Border{
+			{Type: "bottom", Color: "#000000", Style: 1},
+		},
+	})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.diff, err = f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#FFEB9C"}, Pattern: 1},
+	})
+	if err != nil {
+		return styles, err
+	}
+
+	// Decision cells are filled in by reviewers
+	styles.decision, err = f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#E2EFDA"}, Pattern: 1},
+		Border: []excelize.Border{
+			{Type: "left", Color: "#A9D08E", Style: 1},
+			{Type: "right", Color: "#A9D08E", Style: 1},
+			{Type: "bottom", Color: "#A9D08E", Style: 1},
+		},
+	})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.notCompared, err = f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Italic: true, Color: "#9C0006"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#FFC7CE"}, Pattern: 1},
+	})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.percent, err = f.NewStyle(&excelize.Style{NumFmt: 2})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.link, err = f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "#0563C1", Underline: "single"},
+	})
+	if err != nil {
+		return styles, err
+	}
+
+	// Date and timestamp cells, plain and highlighted
+	dateFormat, dateTimeFormat := "yyyy-mm-dd", "yyyy-mm-dd hh:mm:ss"
+	diffFill := excelize.Fill{Type: "pattern", Color: []string{"#FFEB9C"}, Pattern: 1}
+	if styles.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return styles, err
+	}
+	if styles.dateDiff, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat, Fill: diffFill}); err != nil {
+		return styles, err
+	}
+	if styles.dateTime, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateTimeFormat}); err != nil {
+		return styles, err
+	}
+	if styles.dateTimeDiff, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateTimeFormat, Fill: diffFill}); err != nil {
+		return styles, err
+	}
+
+	durationFormat := "[h]:mm:ss.000"
+	if styles.duration, err = f.NewStyle(&excelize.Style{CustomNumFmt: &durationFormat}); err != nil {
+		return styles, err
+	}
+
+	styles.condWarning, err = f.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "#9C5700"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#FFEB9C"}, Pattern: 1},
+	})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.condError, err = f.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "#9C0006"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#FFC7CE"}, Pattern: 1},
+	})
+	return styles, err
+}
+
+// Function to convert a database value into a typed Excel cell: timestamps
+// and dates become real dates, numeric text becomes a number, and binary
+// data is shown as hex. highlight selects the "difference" fill.
+func (s reportStyles) typedCell(val interface{}, dataType string, highlight bool) excelize.Cell {
+	styleID := 0
+	if highlight {
+		styleID = s.diff
+	}
+
+	switch v := val.(type) {
+	case time.Time:
+		if isDateOnlyType(dataType) {
+			styleID = s.date
+			if highlight {
+				styleID = s.dateDiff
+			}
+		} else {
+			styleID = s.dateTime
+			if highlight {
+				styleID = s.dateTimeDiff
+			}
+		}
+		// Excel has no time zones; show timestamps in UTC
+		return excelize.Cell{StyleID: styleID, Value: v.UTC()}
+	case *time.Time:
+		if v == nil {
+			return excelize.Cell{StyleID: styleID}
+		}
+		return s.typedCell(*v, dataType, highlight)
+	case []byte:
+		if !utf8.Valid(v) {
+			return excelize.Cell{StyleID: styleID, Value: "\\x" + hex.EncodeToString(v)}
+		}
+		return excelize.Cell{StyleID: styleID, Value: string(v)}
+	case string:
+		if isNumericType(dataType) {
+			// Keep long numerics as text so no precision is lost
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && len(v) <= 15 {
+				return excelize.Cell{StyleID: styleID, Value: f}
+			}
+		}
+	}
+
+	return excelize.Cell{StyleID: styleID, Value: val}
+}
+
+// Function to write a key value as a text cell. Apply finds rows by these
+// cells, and Excel numbers and dates would lose digits beyond 15 significant
+// ones or below a millisecond.
+func (s reportStyles) keyCell(val interface{}, dataType string, highlight bool) excelize.Cell {
+	styleID := 0
+	if highlight {
+		styleID = s.diff
+	}
+
+	switch v := val.(type) {
+	case nil:
+		return excelize.Cell{StyleID: styleID}
+	case time.Time:
+		if isDateOnlyType(dataType) {
+			return excelize.Cell{StyleID: styleID, Value: v.Format("2006-01-02")}
+		}
+		return excelize.Cell{StyleID: styleID, Value: v.UTC().Format(time.RFC3339Nano)}
+	case *time.Time:
+		if v == nil {
+			return excelize.Cell{StyleID: styleID}
+		}
+		return s.keyCell(*v, dataType, highlight)
+	case []byte:
+		return s.typedCell(v, dataType, highlight)
+	}
+
+	return excelize.Cell{StyleID: styleID, Value: fmt.Sprintf("%v", val)}
+}
+
+// Helper function to check whether a PostgreSQL data type is a date without time
+func isDateOnlyType(dataType string) bool {
+	return strings.EqualFold(dataType, "date")
+}
+
+// Helper function to check whether a PostgreSQL data type is numeric
+func isNumericType(dataType string) bool {
+	switch strings.ToLower(dataType) {
+	case "numeric", "decimal", "real", "double precision", "smallint", "integer", "bigint", "money":
+		return true
+	}
+	return false
+}
+
+// Helper for writing a sheet row by row with the excelize stream writer, so
+// memory stays low for large tables
+type sheetWriter struct {
+	f          *excelize.File
+	sheet      string
+	sw         *excelize.StreamWriter
+	row        int
+	headerRow  int
+	headerCols int
+}
+
+// Function to create a sheet and a stream writer for it. Column widths must
+// be set before the first row is written. Rows up to and including headerRow
+// stay frozen when scrolling.
+func newSheetWriter(f *excelize.File, sheet string, widths []float64, headerRow int) (*sheetWriter, error) {
+	if index, _ := f.GetSheetIndex(sheet); index < 0 {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return nil, fmt.Errorf("failed to create sheet %s: %w", sheet, err)
+		}
+	}
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream writer for sheet %s: %w", sheet, err)
+	}
+
+	for i, width := range widths {
+		if err := sw.SetColWidth(i+1, i+1, width); err != nil {
+			return nil, fmt.Errorf("failed to set column width on sheet %s: %w", sheet, err)
+		}
+	}
+
+	if headerRow > 0 {
+		topLeft, _ := excelize.CoordinatesToCellName(1, headerRow+1)
+		err := sw.SetPanes(&excelize.Panes{
+			Freeze:      true,
+			YSplit:      headerRow,
+			TopLeftCell: topLeft,
+			ActivePane:  "bottomLeft",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to freeze header on sheet %s: %w", sheet, err)
+		}
+	}
+
+	return &sheetWriter{f: f, sheet: sheet, sw: sw}, nil
+}
+
+// Function to append a row, optionally applying a style to every cell
+func (w *sheetWriter) writeRow(values []interface{}, styleID int) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	return w.sw.SetRow(cell, values, excelize.RowOpts{StyleID: styleID})
+}
+
+// Function to add an in-workbook hyperlink to a cell of the next row. The
+// hyperlink is kept on the worksheet and written out when the stream is flushed.
+func (w *sheetWriter) link(col int, location string) error {
+	cell, err := excelize.CoordinatesToCellName(col, w.row+1)
+	if err != nil {
+		return err
+	}
+	return w.f.SetCellHyperLink(w.sheet, cell, location, "Location")
+}
+
+// Function to append the header row
+func (w *sheetWriter) writeHeader(headers []string, styleID int) error {
+	values := make([]interface{}, len(headers))
+	for i, header := range headers {
+		values[i] = header
+	}
+	if err := w.writeRow(values, styleID); err != nil {
+		return err
+	}
+
+	w.headerRow = w.row
+	w.headerCols = len(headers)
+	return nil
+}
+
+// Function to add conditional formatting to a column for all data rows
+// written so far. Must be called before flush.
+func (w *sheetWriter) conditionalFormat(col int, opts ...excelize.ConditionalFormatOptions) error {
+	if w.row <= w.headerRow {
+		return nil
+	}
+	first, _ := excelize.CoordinatesToCellName(col, w.headerRow+1)
+	last, _ := excelize.CoordinatesToCellName(col, w.row)
+	return w.f.SetConditionalFormat(w.sheet, first+":"+last, opts)
+}
+
+// Function to restrict a column of all data rows written so far to a list
+// of values, shown as a dropdown. Must be called before flush.
+func (w *sheetWriter) dropdown(col int, values []string) error {
+	if w.row <= w.headerRow {
+		return nil
+	}
+	first, _ := excelize.CoordinatesToCellName(col, w.headerRow+1)
+	last, _ := excelize.CoordinatesToCellName(col, w.row)
+
+	dv := excelize.NewDataValidation(true)
+	dv.Sqref = first + ":" + last
+	if err := dv.SetDropList(values); err != nil {
+		return err
+	}
+	return w.f.AddDataValidation(w.sheet, dv)
+}
+
+// Function to finish writing the sheet, adding an autofilter on the header row
+func (w *sheetWriter) flush() error {
+	if w.headerCols > 0 {
+		first, _ := excelize.CoordinatesToCellName(1, w.headerRow)
+		lastRow := w.row
+		if lastRow == w.headerRow {
+			lastRow++
+		}
+		last, _ := excelize.CoordinatesToCellName(w.headerCols, lastRow)
+		if err := w.f.AutoFilter(w.sheet, first+":"+last, nil); err != nil {
+			return fmt.Errorf("failed to add autofilter on sheet %s: %w", w.sheet, err)
+		}
+	}
+	return w.sw.Flush()
+}
+
+// Helper function to repeat a column width for n columns
+func repeatWidth(width float64, n int) []float64 {
+	widths := make([]float64, n)
+	for i := range widths {
+		widths[i] = width
+	}
+	return widths
+}
+
+// Kinds of detail sheets created per table, in sheet order
+var detailSheetKinds = []struct {
+	suffix string
+	label  string
+}{
+	{"Diff", "Diff"},
+	{"Records", "Records"},
+	{"JSONDiff", "JSON Diff"},
+	{"OnlyInDev", "Only in Dev"},
+	{"OnlyInStaging", "Only in Staging"},
+	{"DeletedInStaging", "Deleted in Staging"},
+	{"DeletedInDev", "Deleted in Dev"},
+}
+
+// A detail sheet planned for one table
+type detailSheet struct {
+	name       string
+	label      string
+	tableName  string
+	keyColumns []string
+	rows       int
+	backLink   string // Summary cell of the table, for the link back
+}
+
+// Function to decide up front which detail sheets each table gets and under
+// which unique name, so the Summary and Index sheets can link to them
+func planDetailSheets(results []map[string]interface{}, exportOpts ExportOptions, namer *sheetNamer) []map[string]detailSheet {
+	showColumnView := exportOpts.DiffView != "record"
+	showRecordView := exportOpts.DiffView == "record" || exportOpts.DiffView == "both"
+
+	plans := make([]map[string]detailSheet, len(results))
+	for i, result := range results {
+		tableName := result["table_name"].(string)
+		differences := result["differences"].([]map[string]interface{})
+		changedRecords, _ := result["changed_records"].([]map[string]interface{})
+		deletedInStaging, _ := result["deleted_in_staging"].([]map[string]interface{})
+		deletedInDev, _ := result["deleted_in_dev"].([]map[string]interface{})
+
+		rows := map[string]int{
+			"JSONDiff":         len(collectJSONDiffRows(differences)),
+			"OnlyInDev":        len(result["only_in_dev"].([]map[string]interface{})),
+			"OnlyInStaging":    len(result["only_in_staging"].([]map[string]interface{})),
+			"DeletedInStaging": len(deletedInStaging),
+			"DeletedInDev":     len(deletedInDev),
+		}
+		if showColumnView {
+			rows["Diff"] = len(differences)
+		}
+		if showRecordView {
+			rows["Records"] = len(changedRecords)
+		}
+
+		backCell, _ := excelize.CoordinatesToCellName(1, i+2)
+		plans[i] = make(map[string]detailSheet)
+		for _, kind := range detailSheetKinds {
+			if rows[kind.suffix] == 0 {
+				continue
+			}
+			plans[i][kind.suffix] = detailSheet{
+				name:       namer.name(tableName, kind.suffix),
+				label:      kind.label,
+				tableName:  tableName,
+				keyColumns: result["primary_keys"].([]string),
+				rows:       rows[kind.suffix],
+				backLink:   sheetLocation(summarySheetName, backCell),
+			}
+		}
+	}
+
+	return plans
+}
+
+// Names of the fixed sheets of the report
+const (
+	summarySheetName     = "Summary"
+	indexSheetName       = "Index"
+	columnStatsSheetName = "Column Stats"
+	runInfoSheetName     = "Run Info"
+	objectsSheetName     = "Objects"
+)
+
+// Function to export comparison results to Excel
+func exportToExcel(results []map[string]interface{}, filename string, exportOpts ExportOptions) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newReportStyles(f)
+	if err != nil {
+		return fmt.Errorf("failed to create report styles: %w", err)
+	}
+
+	// Create summary sheet
+	if err := f.SetSheetName("Sheet1", summarySheetName); err != nil {
+		return fmt.Errorf("failed to create summary sheet: %w", err)
+	}
+
+	namer := newSheetNamer(summarySheetName, indexSheetName, columnStatsSheetName, runInfoSheetName, objectsSheetName)
+	plans := planDetailSheets(results, exportOpts, namer)
+
+	// Tables a stopped run didn't get to are listed on the Summary sheet too
+	var notCompared []string
+	var failed []tableFailure
+	if exportOpts.RunInfo != nil {
+		notCompared = exportOpts.RunInfo.NotCompared
+		failed = exportOpts.RunInfo.Failed
+	}
+
+	if err := createSummarySheet(f, results, plans, notCompared, failed, styles); err != nil {
+		return err
+	}
+
+	// Create index and column statistics sheets next to the summary
+	if err := createIndexSheet(f, plans, styles); err != nil {
+		return err
+	}
+	if err := createColumnStatsSheet(f, results, styles); err != nil {
+		return err
+	}
+	if len(exportOpts.ObjectKinds) > 0 {
+		if err := createObjectsSheet(f, exportOpts.Objects, styles); err != nil {
+			return err
+		}
+	}
+	if exportOpts.RunInfo != nil {
+		if err := createRunInfoSheet(f, results, exportOpts.RunInfo, styles); err != nil {
+			return err
+		}
+	}
+
+	// Create detailed sheets for each table
+	for i, result := range results {
+		tableName := result["table_name"].(string)
+		if err := createDetailedSheets(f, result, plans[i], styles); err != nil {
+			return fmt.Errorf("failed to create sheets for table %s: %w", tableName, err)
+		}
+	}
+
+	// Save the Excel file
+	if err := f.SaveAs(filename); err != nil {
+		return fmt.Errorf("failed to save Excel file: %w", err)
+	}
+
+	return nil
+}
+
+// Helper function to create the summary sheet with one row per compared table
+// and links to the table's detail sheets
+func createSummarySheet(f *excelize.File, results []map[string]interface{}, plans []map[string]detailSheet, notCompared []string, failed []tableFailure, styles reportStyles) error {
+	widths := append([]float64{20, 18, 15, 15, 15, 15, 15, 15}, repeatWidth(22, len(detailSheetKinds))...)
+	w, err := newSheetWriter(f, summarySheetName, widths, 1)
+	if err != nil {
+		return err
+	}
+
+	// Set summary sheet headers
+	headers := []string{"Table Name", "PK Type", "Dev Count", "Staging Count", "Count Difference", "Value Differences", "Only in Dev", "Only in Staging"}
+	linkColumn := len(headers) + 1
+	for _, kind := range detailSheetKinds {
+		headers = append(headers, kind.label+" Sheet")
+	}
+	if err := w.writeHeader(headers, styles.header); err != nil {
+		return err
+	}
+
+	// Fill summary data
+	for i, result := range results {
+		tableName := result["table_name"].(string)
+		differences := result["differences"].([]map[string]interface{})
+		onlyInDev := result["only_in_dev"].([]map[string]interface{})
+		onlyInStaging := result["only_in_staging"].([]map[string]interface{})
+		primaryKeys := result["primary_keys"].([]string)
+		hasPrimaryKey := result["has_primary_key"].(bool)
+		usingComposite := result["using_composite"].(bool)
+
+		// Determine what kind of key is being used for comparison
+		var keyTypeText string
+		if countMethod, ok := result["count_method"].(string); ok {
+			keyTypeText = fmt.Sprintf("Counts only (%s)", countMethod)
+		} else if !hasPrimaryKey {
+			keyTypeText = "All Columns"
+		} else if usingComposite {
+			keyTypeText = fmt.Sprintf("Composite (%d cols)", len(primaryKeys))
+		} else {
+			keyTypeText = primaryKeys[0] // Single column PK
+		}
+
+		// Add color to rows with differences
+		rowStyle := 0
+		if len(differences) > 0 || len(onlyInDev) > 0 || len(onlyInStaging) > 0 || plans[i]["DeletedInStaging"].rows > 0 || plans[i]["DeletedInDev"].rows > 0 {
+			rowStyle = styles.diff
+		}
+
+		values := []interface{}{
+			tableName,
+			keyTypeText,
+			result["dev_count"],
+			result["staging_count"],
+			result["count_diff"],
+			len(differences),
+			len(onlyInDev),
+			len(onlyInStaging),
+		}
+
+		// Link to each of the table's detail sheets
+		for j, kind := range detailSheetKinds {
+			sheet, ok := plans[i][kind.suffix]
+			if !ok {
+				values = append(values, nil)
+				continue
+			}
+			values = append(values, excelize.Cell{StyleID: styles.link, Value: sheet.name})
+			if err := w.link(linkColumn+j, sheetLocation(sheet.name, "A1")); err != nil {
+				return err
+			}
+		}
+
+		if err := w.writeRow(values, rowStyle); err != nil {
+			return err
+		}
+	}
+
+	// Mark the report as partial by listing the tables that failed or weren't compared
+	for _, failure := range failed {
+		if err := w.writeRow([]interface{}{failure.Table, "FAILED: " + failure.Error}, styles.notCompared); err != nil {
+			return err
+		}
+	}
+	for _, tableName := range notCompared {
+		if err := w.writeRow([]interface{}{tableName, "NOT COMPARED (run stopped)"}, styles.notCompared); err != nil {
+			return err
+		}
+	}
+
+	// Highlight count mismatches and tables with differences
+	err = w.conditionalFormat(5, excelize.ConditionalFormatOptions{Type: "cell", Criteria: "!=", Value: "0", Format: styles.condError})
+	if err != nil {
+		return err
+	}
+	for col := 6; col <= 8; col++ {
+		err := w.conditionalFormat(col, excelize.ConditionalFormatOptions{Type: "cell", Criteria: ">", Value: "0", Format: styles.condWarning})
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+// Helper function to create the index sheet listing every detail sheet
+func createIndexSheet(f *excelize.File, plans []map[string]detailSheet, styles reportStyles) error {
+	w, err := newSheetWriter(f, indexSheetName, []float64{34, 25, 18, 12, 30}, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := w.writeHeader([]string{"Sheet", "Table Name", "Content", "Rows", "Key Columns"}, styles.header); err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		for _, kind := range detailSheetKinds {
+			sheet, ok := plan[kind.suffix]
+			if !ok {
+				continue
+			}
+			if err := w.link(1, sheetLocation(sheet.name, "A1")); err != nil {
+				return err
+			}
+			err := w.writeRow([]interface{}{
+				excelize.Cell{StyleID: styles.link, Value: sheet.name},
+				sheet.tableName,
+				sheet.label,
+				sheet.rows,
+				strings.Join(sheet.keyColumns, ", "),
+			}, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.flush()
+}
+
+// Helper function to create the sheet with per-column drift statistics of all tables
+func createColumnStatsSheet(f *excelize.File, results []map[string]interface{}, styles reportStyles) error {
+	w, err := newSheetWriter(f, columnStatsSheetName, []float64{20, 20, 15, 15, 15, 15, 80}, 1)
+	if err != nil {
+		return err
+	}
+
+	headers := []string{"Table Name", "Column", "Rows Differing", "% of Matched Rows", "Distinct Dev", "Distinct Staging", "Top Transitions (dev→staging)"}
+	if err := w.writeHeader(headers, styles.header); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		columnStats, _ := result["column_stats"].([]map[string]interface{})
+		for _, stat := range columnStats {
+			err := w.writeRow([]interface{}{
+				result["table_name"],
+				stat["column"],
+				stat["rows_differing"],
+				excelize.Cell{StyleID: styles.percent, Value: stat["differing_pct"]},
+				stat["distinct_dev"],
+				stat["distinct_staging"],
+				formatTransitions(stat["top_transitions"].([]valueTransition)),
+			}, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Shade the drift percentage from white (no drift) to red (every row)
+	err = w.conditionalFormat(4, excelize.ConditionalFormatOptions{
+		Type:     "2_color_scale",
+		Criteria: "=",
+		MinType:  "num",
+		MinValue: "0",
+		MaxType:  "num",
+		MaxValue: "100",
+		MinColor: "#FFFFFF",
+		MaxColor: "#F8696B",
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.flush()
+}
+
+// Helper function to create the sheet with the differences in database
+// objects (sequences, enums, ...), one row per differing attribute
+func createObjectsSheet(f *excelize.File, diffs []objectDiff, styles reportStyles) error {
+	w, err := newSheetWriter(f, objectsSheetName, []float64{12, 30, 15, 16, 40, 40, 40}, 1)
+	if err != nil {
+		return err
+	}
+
+	headers := []string{"Type", "Name", "Attribute", "Status", "Dev", "Staging", "Details"}
+	if err := w.writeHeader(headers, styles.header); err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		rowStyle := 0
+		if diff.Status == objectBehindTable {
+			rowStyle = styles.diff
+		}
+		err := w.writeRow([]interface{}{diff.Kind, diff.Name, diff.Attribute, diff.Status, diff.Dev, diff.Staging, diff.Details}, rowStyle)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+// Helper function to create the sheet describing the run: tool version,
+// connections, flags, timing and per-table duration
+func createRunInfoSheet(f *excelize.File, results []map[string]interface{}, runInfo *RunInfo, styles reportStyles) error {
+	w, err := newSheetWriter(f, runInfoSheetName, []float64{30, 60}, 1)
+	if err != nil {
+		return err
+	}
+	if err := w.writeHeader([]string{"Property", "Value"}, styles.header); err != nil {
+		return err
+	}
+
+	rows := [][]interface{}{
+		{"Status", runInfo.status()},
+		{"Tool Version", runInfo.ToolVersion},
+		{"Started At", excelize.Cell{StyleID: styles.dateTime, Value: runInfo.StartedAt.UTC()}},
+		{"Finished At", excelize.Cell{StyleID: styles.dateTime, Value: runInfo.FinishedAt.UTC()}},
+		{"Total Duration", excelize.Cell{StyleID: styles.duration, Value: runInfo.FinishedAt.Sub(runInfo.StartedAt)}},
+	}
+	if runInfo.DevFile != "" {
+		rows = append(rows, []interface{}{"Dev Master-Data File", runInfo.DevFile})
+	} else {
+		rows = append(rows, []interface{}{"Dev Database", runInfo.DevConfig.String()})
+	}
+	if runInfo.StagingFile != "" {
+		rows = append(rows, []interface{}{"Staging Master-Data File", runInfo.StagingFile})
+	} else if runInfo.SameDatabase {
+		rows = append(rows, []interface{}{"Staging Database", runInfo.StagingConfig.String() + " (same connection as dev)"})
+	} else {
+		rows = append(rows, []interface{}{"Staging Database", runInfo.StagingConfig.String()})
+	}
+	if runInfo.DevSchema != "" && runInfo.DevSchema != "public" {
+		rows = append(rows, []interface{}{"Dev Schema", runInfo.DevSchema})
+	}
+	if runInfo.StagingSchema != "" && runInfo.StagingSchema != "public" {
+		rows = append(rows, []interface{}{"Staging Schema", runInfo.StagingSchema})
+	}
+	if runInfo.Mode != "" {
+		rows = append(rows, []interface{}{"Mode", runInfo.Mode})
+	}
+	snapshot := "per query (autocommit)"
+	if runInfo.Consistent {
+		snapshot = "one snapshot per database (REPEATABLE READ, READ ONLY)"
+	}
+	rows = append(rows,
+		[]interface{}{"Read Consistency", snapshot},
+		[]interface{}{"Tables Compared", len(results)},
+	)
+	for _, failure := range runInfo.Failed {
+		rows = append(rows, []interface{}{"Failed", fmt.Sprintf("%s: %s", failure.Table, failure.Error)})
+	}
+	if len(runInfo.Objects) > 0 {
+		rows = append(rows, []interface{}{"Objects Compared", strings.Join(runInfo.Objects, ", ")})
+	}
+	for _, objectError := range runInfo.ObjectErrors {
+		rows = append(rows, []interface{}{"Objects Not Compared", objectError})
+	}
+	if runInfo.Resumed != "" {
+		rows = append(rows, []interface{}{"Resumed", runInfo.Resumed})
+	}
+	if sd := runInfo.SoftDelete; sd != nil {
+		rows = append(rows, []interface{}{"Soft Deletes", fmt.Sprintf("%s (%s)", sd.Mode, strings.Join(append(append([]string{}, sd.FlagColumns...), sd.TimestampColumns...), ", "))})
+	}
+	for _, mask := range runInfo.Masks {
+		rows = append(rows, []interface{}{"Masked Columns", mask})
+	}
+	for _, mapping := range runInfo.Mappings {
+		rows = append(rows, []interface{}{"Mapping", mapping})
+	}
+	for _, query := range runInfo.Queries {
+		rows = append(rows, []interface{}{
+			fmt.Sprintf("Query: %s", query.Name),
+			fmt.Sprintf("%s (key: %s)", strings.TrimSpace(query.SQL), strings.Join(query.Key, ", ")),
+		})
+	}
+	for _, tableName := range runInfo.NotCompared {
+		rows = append(rows, []interface{}{"Not Compared", tableName})
+	}
+	for _, fl := range runInfo.Flags {
+		rows = append(rows, []interface{}{"Flag", fl})
+	}
+	for _, result := range results {
+		if duration, ok := result["duration"].(time.Duration); ok {
+			rows = append(rows, []interface{}{
+				fmt.Sprintf("Duration: %s", result["table_name"]),
+				excelize.Cell{StyleID: styles.duration, Value: duration},
+			})
+		}
+	}
+
+	for _, row := range rows {
+		if err := w.writeRow(row, 0); err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+// Helper function to create detailed sheets for each table comparison
+func createDetailedSheets(f *excelize.File, result map[string]interface{}, plan map[string]detailSheet, styles reportStyles) error {
+	columns := result["columns"].([]string)
+	columnTypes, _ := result["column_types"].(map[string]string)
+	primaryKeys := result["primary_keys"].([]string)
+	differences := result["differences"].([]map[string]interface{})
+	changedRecords, _ := result["changed_records"].([]map[string]interface{})
+	onlyInDev := result["only_in_dev"].([]map[string]interface{})
+	onlyInStaging := result["only_in_staging"].([]map[string]interface{})
+
+	if sheet, ok := plan["Diff"]; ok {
+		if err := writeDiffSheet(f, sheet, primaryKeys, columnTypes, differences, styles); err != nil {
+			return err
+		}
+	}
+
+	if sheet, ok := plan["Records"]; ok {
+		if err := writeRecordSheet(f, sheet, columns, columnTypes, changedRecords, styles); err != nil {
+			return err
+		}
+	}
+
+	if sheet, ok := plan["JSONDiff"]; ok {
+		if err := writeJSONDiffSheet(f, sheet, differences, styles); err != nil {
+			return err
+		}
+	}
+
+	if sheet, ok := plan["OnlyInDev"]; ok {
+		if err := writeOnlyInSheet(f, sheet, columns, primaryKeys, columnTypes, onlyInDev, styles); err != nil {
+			return err
+		}
+	}
+
+	if sheet, ok := plan["OnlyInStaging"]; ok {
+		if err := writeOnlyInSheet(f, sheet, columns, primaryKeys, columnTypes, onlyInStaging, styles); err != nil {
+			return err
+		}
+	}
+
+	// Rows soft-deleted on one side only, with both versions side by side
+	if sheet, ok := plan["DeletedInStaging"]; ok {
+		deletedInStaging, _ := result["deleted_in_staging"].([]map[string]interface{})
+		if err := writeRecordSheet(f, sheet, columns, columnTypes, deletedInStaging, styles); err != nil {
+			return err
+		}
+	}
+
+	if sheet, ok := plan["DeletedInDev"]; ok {
+		deletedInDev, _ := result["deleted_in_dev"].([]map[string]interface{})
+		if err := writeRecordSheet(f, sheet, columns, columnTypes, deletedInDev, styles); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Function to create the writer for a detail sheet. The first row links back
+// to the table's Summary row; the header goes on the second row.
+func newDetailSheetWriter(f *excelize.File, sheet detailSheet, widths []float64, styles reportStyles) (*sheetWriter, error) {
+	w, err := newSheetWriter(f, sheet.name, widths, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.link(1, sheet.backLink); err != nil {
+		return nil, err
+	}
+	err = w.writeRow([]interface{}{
+		excelize.Cell{StyleID: styles.link, Value: "← Back to Summary"},
+		fmt.Sprintf("%s: %s", sheet.tableName, sheet.label),
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Helper function to create a differences sheet with one row per changed column
+func writeDiffSheet(f *excelize.File, sheet detailSheet, primaryKeys []string, columnTypes map[string]string, differences []map[string]interface{}, styles reportStyles) error {
+	// Headers for diff sheet
+	diffHeaders := []string{decisionHeader, "Primary Key"}
+	diffHeaders = append(diffHeaders, primaryKeys...)
+	diffHeaders = append(diffHeaders, "Column", "Dev Value", "Staging Value")
+
+	w, err := newDetailSheetWriter(f, sheet, append([]float64{14}, repeatWidth(18, len(diffHeaders)-1)...), styles)
+	if err != nil {
+		return err
+	}
+	if err := w.writeHeader(diffHeaders, styles.header); err != nil {
+		return err
+	}
+
+	// Fill differences data
+	for _, diff := range differences {
+		column, _ := diff["column"].(string)
+
+		// Add background color for easy visibility
+		values := []interface{}{
+			excelize.Cell{StyleID: styles.decision},
+			styles.typedCell(diff["key"], "", true),
+		}
+		for _, pk := range primaryKeys {
+			values = append(values, styles.keyCell(diff["pk_"+pk], columnTypes[pk], true))
+		}
+		values = append(values,
+			styles.typedCell(column, "", true),
+			styles.typedCell(diff["dev_value"], columnTypes[column], true),
+			styles.typedCell(diff["staging_value"], columnTypes[column], true),
+		)
+
+		if err := w.writeRow(values, styles.diff); err != nil {
+			return err
+		}
+	}
+
+	if err := w.dropdown(1, decisionOptions); err != nil {
+		return err
+	}
+	return w.flush()
+}
+
+// Helper function to create a record-level differences sheet: one row per
+// changed record with dev and staging values side by side and the changed
+// cells highlighted
+func writeRecordSheet(f *excelize.File, sheet detailSheet, columns []string, columnTypes map[string]string, changedRecords []map[string]interface{}, styles reportStyles) error {
+	// Headers: key, then a dev/staging pair for every column
+	recordHeaders := []string{"Primary Key", "Changed Columns"}
+	for _, col := range columns {
+		recordHeaders = append(recordHeaders, col+" (dev)", col+" (staging)")
+	}
+
+	widths := append([]float64{20, 20}, repeatWidth(15, len(columns)*2)...)
+	w, err := newDetailSheetWriter(f, sheet, widths, styles)
+	if err != nil {
+		return err
+	}
+	if err := w.writeHeader(recordHeaders, styles.header); err != nil {
+		return err
+	}
+
+	for _, record := range changedRecords {
+		devRow := record["dev_row"].(map[string]interface{})
+		stagingRow := record["staging_row"].(map[string]interface{})
+		changedColumns := record["changed_columns"].([]string)
+
+		changed := make(map[string]bool)
+		for _, col := range changedColumns {
+			changed[col] = true
+		}
+
+		values := []interface{}{record["key"], strings.Join(changedColumns, ", ")}
+		for _, col := range columns {
+			values = append(values,
+				styles.typedCell(devRow[col], columnTypes[col], changed[col]),
+				styles.typedCell(stagingRow[col], columnTypes[col], changed[col]),
+			)
+		}
+
+		if err := w.writeRow(values, 0); err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+// Helper function to flatten the path-level differences of JSON columns into report rows
+func collectJSONDiffRows(differences []map[string]interface{}) [][]interface{} {
+	var jsonDiffRows [][]interface{}
+	for _, diff := range differences {
+		paths, ok := diff["json_paths"].([]jsonPathDiff)
+		if !ok {
+			continue
+		}
+		for _, p := range paths {
+			jsonDiffRows = append(jsonDiffRows, []interface{}{diff["key"], diff["column"], p.Path, p.DevValue, p.StagingValue})
+		}
+	}
+	return jsonDiffRows
+}
+
+// Helper function to create a sheet with the path-level differences of JSON columns
+func writeJSONDiffSheet(f *excelize.File, sheet detailSheet, differences []map[string]interface{}, styles reportStyles) error {
+	w, err := newDetailSheetWriter(f, sheet, []float64{18, 18, 30, 25, 25}, styles)
+	if err != nil {
+		return err
+	}
+	if err := w.writeHeader([]string{"Primary Key", "Column", "Path", "Dev Value", "Staging Value"}, styles.header); err != nil {
+		return err
+	}
+
+	for _, row := range collectJSONDiffRows(differences) {
+		if err := w.writeRow(row, 0); err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+// Helper function to create a sheet listing the rows that exist in one
+// environment only, with a Decision column for reviewers
+func writeOnlyInSheet(f *excelize.File, sheet detailSheet, columns, primaryKeys []string, columnTypes map[string]string, rows []map[string]interface{}, styles reportStyles) error {
+	w, err := newDetailSheetWriter(f, sheet, append([]float64{14}, repeatWidth(15, len(columns))...), styles)
+	if err != nil {
+		return err
+	}
+	if err := w.writeHeader(append([]string{decisionHeader}, columns...), styles.header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		values := []interface{}{excelize.Cell{StyleID: styles.decision}}
+		for _, col := range columns {
+			if containsString(primaryKeys, col) {
+				values = append(values, styles.keyCell(row[col], columnTypes[col], false))
+				continue
+			}
+			values = append(values, styles.typedCell(row[col], columnTypes[col], false))
+		}
+		if err := w.writeRow(values, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := w.dropdown(1, decisionOptions); err != nil {
+		return err
+	}
+	return w.flush()
+}
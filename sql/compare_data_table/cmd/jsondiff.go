@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A single differing path between the dev and staging JSON documents
+type jsonPathDiff struct {
+	Path         string      `json:"path"`
+	DevValue     interface{} `json:"dev_value"`
+	StagingValue interface{} `json:"staging_value"`
+}
+
+// Marker for a path that exists in only one of the documents
+type jsonMissing struct{}
+
+func (jsonMissing) String() string { return "(missing)" }
+
+// Object keys that can be written as $.key instead of $["key"]
+var jsonSimpleKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Helper function to check whether a PostgreSQL data type holds JSON
+func isJSONType(dataType string) bool {
+	dataType = strings.ToLower(dataType)
+	return dataType == "json" || dataType == "jsonb"
+}
+
+// Function to decode a JSON column value into a generic document.
+// Numbers are kept as json.Number so precision is not lost.
+func decodeJSONValue(val interface{}) (interface{}, bool) {
+	var raw []byte
+	switch v := val.(type) {
+	case nil:
+		return nil, true
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	case json.RawMessage:
+		raw = v
+	default:
+		// Already decoded by the driver (map, slice, ...) - round-trip it
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
+		}
+		raw = encoded
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false
+	}
+	if decoder.More() {
+		return nil, false
+	}
+
+	return doc, true
+}
+
+// Function to compute the path-level differences between two JSON values.
+// The second return value is false when either side is not valid JSON.
+func diffJSONValues(devVal, stagingVal interface{}) ([]jsonPathDiff, bool) {
+	devDoc, ok := decodeJSONValue(devVal)
+	if !ok {
+		return nil, false
+	}
+	stagingDoc, ok := decodeJSONValue(stagingVal)
+	if !ok {
+		return nil, false
+	}
+
+	var diffs []jsonPathDiff
+	diffJSON("$", devDoc, stagingDoc, &diffs)
+	return diffs, true
+}
+
+// Function to walk two JSON documents and collect differing paths.
+// Object key order is ignored; arrays are compared by position.
+func diffJSON(path string, dev, staging interface{}, diffs *[]jsonPathDiff) {
+	switch devNode := dev.(type) {
+	case map[string]interface{}:
+		stagingNode, ok := staging.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make(map[string]bool)
+		for k := range devNode {
+			keys[k] = true
+		}
+		for k := range stagingNode {
+			keys[k] = true
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for k := range keys {
+			sortedKeys = append(sortedKeys, k)
+		}
+		sort.Strings(sortedKeys)
+
+		for _, k := range sortedKeys {
+			childPath := jsonChildPath(path, k)
+			devChild, inDev := devNode[k]
+			stagingChild, inStaging := stagingNode[k]
+			switch {
+			case !inDev:
+				*diffs = append(*diffs, jsonPathDiff{Path: childPath, DevValue: jsonMissing{}, StagingValue: jsonLeaf(stagingChild)})
+			case !inStaging:
+				*diffs = append(*diffs, jsonPathDiff{Path: childPath, DevValue: jsonLeaf(devChild), StagingValue: jsonMissing{}})
+			default:
+				diffJSON(childPath, devChild, stagingChild, diffs)
+			}
+		}
+		return
+
+	case []interface{}:
+		stagingNode, ok := staging.([]interface{})
+		if !ok {
+			break
+		}
+
+		length := len(devNode)
+		if len(stagingNode) > length {
+			length = len(stagingNode)
+		}
+
+		for i := 0; i < length; i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(devNode):
+				*diffs = append(*diffs, jsonPathDiff{Path: childPath, DevValue: jsonMissing{}, StagingValue: jsonLeaf(stagingNode[i])})
+			case i >= len(stagingNode):
+				*diffs = append(*diffs, jsonPathDiff{Path: childPath, DevValue: jsonLeaf(devNode[i]), StagingValue: jsonMissing{}})
+			default:
+				diffJSON(childPath, devNode[i], stagingNode[i], diffs)
+			}
+		}
+		return
+
+	default:
+		if jsonScalarEqual(dev, staging) {
+			return
+		}
+	}
+
+	// Different types or different scalar values
+	*diffs = append(*diffs, jsonPathDiff{Path: path, DevValue: jsonLeaf(dev), StagingValue: jsonLeaf(staging)})
+}
+
+// Helper function to build the path of an object member
+func jsonChildPath(path, key string) string {
+	if jsonSimpleKey.MatchString(key) {
+		return path + "." + key
+	}
+	quoted, _ := json.Marshal(key)
+	return fmt.Sprintf("%s[%s]", path, quoted)
+}
+
+// Helper function to compare two JSON scalars (numbers compare by value)
+func jsonScalarEqual(dev, staging interface{}) bool {
+	devNum, devIsNum := dev.(json.Number)
+	stagingNum, stagingIsNum := staging.(json.Number)
+	if devIsNum && stagingIsNum {
+		if devNum == stagingNum {
+			return true
+		}
+		devFloat, err1 := devNum.Float64()
+		stagingFloat, err2 := stagingNum.Float64()
+		return err1 == nil && err2 == nil && devFloat == stagingFloat
+	}
+
+	if _, isMap := staging.(map[string]interface{}); isMap {
+		return false
+	}
+	if _, isSlice := staging.([]interface{}); isSlice {
+		return false
+	}
+
+	return dev == staging
+}
+
+// Helper function to turn a JSON node into a readable report value
+func jsonLeaf(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(encoded)
+	case json.Number:
+		return v.String()
+	}
+	return node
+}
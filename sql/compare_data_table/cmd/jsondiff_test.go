@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSONValues(t *testing.T) {
+	missing := jsonMissing{}
+
+	tests := []struct {
+		name         string
+		dev, staging interface{}
+		want         []jsonPathDiff
+	}{
+		{
+			name:    "key order is ignored",
+			dev:     `{"a": 1, "b": [1, 2]}`,
+			staging: []byte(`{"b": [1, 2], "a": 1}`),
+		},
+		{
+			name:    "numbers compare by value",
+			dev:     `{"n": 1.0}`,
+			staging: `{"n": 1}`,
+		},
+		{
+			name:    "changed scalar",
+			dev:     `{"user": {"name": "ann"}}`,
+			staging: `{"user": {"name": "bob"}}`,
+			want:    []jsonPathDiff{{Path: "$.user.name", DevValue: "ann", StagingValue: "bob"}},
+		},
+		{
+			name:    "added and removed keys",
+			dev:     `{"a": 1, "only dev": true}`,
+			staging: `{"a": 1, "b": {"c": 2}}`,
+			want: []jsonPathDiff{
+				{Path: "$.b", DevValue: missing, StagingValue: `{"c":2}`},
+				{Path: `$["only dev"]`, DevValue: true, StagingValue: missing},
+			},
+		},
+		{
+			name:    "arrays compare by position",
+			dev:     `[1, 2, 3]`,
+			staging: `[1, 5]`,
+			want: []jsonPathDiff{
+				{Path: "$[1]", DevValue: "2", StagingValue: "5"},
+				{Path: "$[2]", DevValue: "3", StagingValue: missing},
+			},
+		},
+		{
+			name:    "different types",
+			dev:     `{"a": [1]}`,
+			staging: `{"a": "1"}`,
+			want:    []jsonPathDiff{{Path: "$.a", DevValue: "[1]", StagingValue: "1"}},
+		},
+		{
+			name:    "decoded by the driver",
+			dev:     map[string]interface{}{"a": 1},
+			staging: `{"a": 2}`,
+			want:    []jsonPathDiff{{Path: "$.a", DevValue: "1", StagingValue: "2"}},
+		},
+		{
+			name:    "null against a document",
+			dev:     nil,
+			staging: `{}`,
+			want:    []jsonPathDiff{{Path: "$", DevValue: nil, StagingValue: "{}"}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := diffJSONValues(tt.dev, tt.staging)
+		if !ok {
+			t.Errorf("%s: diffJSONValues reported invalid JSON", tt.name)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: diffJSONValues = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffJSONValuesInvalid(t *testing.T) {
+	tests := []struct{ dev, staging interface{} }{
+		{`{"a":`, `{}`},
+		{`{}`, `not json`},
+		{`{} {}`, `{}`},
+	}
+
+	for _, tt := range tests {
+		if _, ok := diffJSONValues(tt.dev, tt.staging); ok {
+			t.Errorf("diffJSONValues(%v, %v) accepted invalid JSON", tt.dev, tt.staging)
+		}
+	}
+}
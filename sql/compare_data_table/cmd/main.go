@@ -1,32 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"maps"
+	"math/big"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/Alwanly/script-tools/sql/dbconfig"
 	"github.com/joho/godotenv"
-	"github.com/xuri/excelize/v2"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// Database configuration struct
-type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+// Database connection settings, shared with the other script tools
+type DBConfig = dbconfig.Config
+
+// Options that control how table rows are compared
+type CompareOptions struct {
+	Normalizers *NormalizerSet
+
+	// Master-data files used instead of the dev or staging database (optional)
+	DevFile     *masterDataFile
+	StagingFile *masterDataFile
+
+	// Table and column names that differ in staging (optional)
+	Mappings *MappingConfig
+
+	// Treatment of soft-deleted rows (nil: compared like any other row)
+	SoftDelete *SoftDeleteConfig
+
+	// Sensitive columns masked in every output (optional)
+	Masks *MaskSet
+
+	// Fraction of rows compared with -mode=sample (0: all rows), and the salt
+	// of the key hash selecting them
+	SampleRate float64
+	SampleSalt string
+
+	// Schemas the dev and staging tables are read from
+	DevSchema     string
+	StagingSchema string
+}
+
+// Function to get the schema and name of the staging table compared with a
+// dev table. A mapping to "schema.table" overrides the staging schema.
+func (o CompareOptions) stagingRef(devTable string) (string, string) {
+	name := o.Mappings.stagingTable(devTable)
+	if schema, table, ok := strings.Cut(name, "."); ok {
+		return schema, table
+	}
+	return o.StagingSchema, name
+}
+
+// Helper function to qualify a table name with its schema
+func qualifiedName(schema, table string) string {
+	return schema + "." + table
 }
 
 // Function to 	abase
 func connectDB(config DBConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		config.Host, config.User, config.Password, config.DBName, config.Port)
+	dsn, err := config.DSN()
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
@@ -36,20 +81,20 @@ func connectDB(config DBConfig) (*gorm.DB, error) {
 	return db, nil
 }
 
-// Function to get all tables in the database
-func getAllTables(db *gorm.DB) ([]string, error) {
+// Function to get all tables in a schema of the database
+func getAllTables(db *gorm.DB, schema string) ([]string, error) {
 	var tables []string
 
-	// Query to get all table names in public schema
+	// Query to get all table names in the schema
 	query := `
 		SELECT table_name 
 		FROM information_schema.tables 
-		WHERE table_schema = 'public' 
+		WHERE table_schema = ? 
 		AND table_type = 'BASE TABLE'
 		ORDER BY table_name
 	`
 
-	err := db.Raw(query).Scan(&tables).Error
+	err := db.Raw(query, schema).Scan(&tables).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tables: %w", err)
 	}
@@ -57,36 +102,22 @@ func getAllTables(db *gorm.DB) ([]string, error) {
 	return tables, nil
 }
 
-// Function to get master tables in the database
-func getMasterTables(db *gorm.DB) ([]string, error) {
-	var tables []string
-
-	// Query to get all table names in public schema that are likely master tables
-	// This assumes master tables typically have names containing "master" or starting with "m_"
-	// You can customize this query based on your naming convention
-	query := `
-		SELECT table_name 
-		FROM information_schema.tables 
-		WHERE table_schema = 'public' 
-		AND table_type = 'BASE TABLE'
-		AND (
-			table_name LIKE '%master%' 
-			OR table_name LIKE 'm\\_%' 
-			OR table_name IN (
-				'categories', 'products', 'customers', 'suppliers', 
-				'regions', 'countries', 'departments', 'currencies',
-				'users', 'roles', 'permissions'
-			)
-		)
-		ORDER BY table_name
-	`
-
-	err := db.Raw(query).Scan(&tables).Error
+// Function to get master tables in the database, as decided by the
+// classifiers, together with the classification of every table
+func getMasterTables(db *gorm.DB, schema string, classifiers []tableClassifier) ([]string, []tableClassification, error) {
+	classifications, err := classifyTables(db, schema, classifiers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get master tables: %w", err)
+		return nil, nil, fmt.Errorf("failed to get master tables: %w", err)
 	}
 
-	return tables, nil
+	var tables []string
+	for _, c := range classifications {
+		if c.Master {
+			tables = append(tables, c.Name)
+		}
+	}
+
+	return tables, classifications, nil
 }
 
 // Function to filter tables based on pattern
@@ -214,40 +245,126 @@ func tryIdentifyKeyColumns(columns []string) []string {
 	// If no key columns found, return empty slice and let the caller decide what to do
 	return []string{}
 } // Function to compare a specific master table between two databases
-func compareTable(devDB, stagingDB *gorm.DB, tableName string) (map[string]interface{}, error) {
+func compareTable(ctx context.Context, devDB, stagingDB *gorm.DB, tableName string, opts CompareOptions) (map[string]interface{}, error) {
 	// Get column names for the table
 	var columns []string
 	var primaryKeys []string
 
-	// Get all columns
-	err := devDB.Raw("SELECT column_name FROM information_schema.columns WHERE table_schema = 'public' AND table_name = ? ORDER BY ordinal_position",
-		tableName).Scan(&columns).Error
+	// Cancelling the context stops in-flight queries
+	if devDB != nil {
+		devDB = devDB.WithContext(ctx)
+	}
+	if stagingDB != nil {
+		stagingDB = stagingDB.WithContext(ctx)
+	}
+
+	// Table metadata comes from the dev database, or from staging when dev is a master-data file
+	metaDB, metaSchema := devDB, opts.DevSchema
+	if opts.DevFile != nil {
+		metaDB, metaSchema = stagingDB, opts.StagingSchema
+	}
+	devTable := qualifiedName(opts.DevSchema, tableName)
+
+	// Get all columns together with their data types
+	var columnInfo []struct {
+		ColumnName string
+		DataType   string
+	}
+	err := metaDB.Raw("SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = ? AND table_name = ? ORDER BY ordinal_position",
+		metaSchema, tableName).Scan(&columnInfo).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns for table %s: %w", tableName, err)
 	}
 
-	if len(columns) == 0 {
+	if len(columnInfo) == 0 {
 		return nil, fmt.Errorf("no columns found for table %s", tableName)
 	}
 
+	columnTypes := make(map[string]string)
+	for _, info := range columnInfo {
+		columns = append(columns, info.ColumnName)
+		columnTypes[info.ColumnName] = info.DataType
+	}
+
+	// Only compare the columns a master-data file provides
+	for _, file := range []*masterDataFile{opts.DevFile, opts.StagingFile} {
+		if file == nil {
+			continue
+		}
+		fileColumns := file.columnsFor(tableName, columns)
+		if len(fileColumns) == 0 {
+			return nil, fmt.Errorf("master-data file %s has no columns matching table %s", file.path, tableName)
+		}
+		if len(fileColumns) < len(columns) {
+			log.Printf("Master-data file %s provides %d of %d columns for table %s; comparing only: %s",
+				file.path, len(fileColumns), len(columns), tableName, strings.Join(fileColumns, ", "))
+			columns = fileColumns
+		}
+	}
+
 	// Find primary keys
-	err = devDB.Raw(`
+	err = metaDB.Raw(`
 		SELECT kcu.column_name 
 		FROM information_schema.table_constraints tc
 		JOIN information_schema.key_column_usage kcu 
 			ON tc.constraint_name = kcu.constraint_name 
 			AND tc.table_schema = kcu.table_schema
 		WHERE tc.constraint_type = 'PRIMARY KEY' 
-			AND tc.table_schema = 'public' 
+			AND tc.table_schema = ? 
 			AND tc.table_name = ?
 		ORDER BY kcu.ordinal_position
-	`, tableName).Scan(&primaryKeys).Error
+	`, metaSchema, tableName).Scan(&primaryKeys).Error
 
 	if err != nil {
 		log.Printf("Warning: Could not determine primary keys for table %s: %v", tableName, err)
 		primaryKeys = tryIdentifyKeyColumns(columns) // Try to identify potential key columns
 	}
 
+	// The staging table may have another name, and during a migration columns
+	// may be renamed, added or dropped; only columns on both sides are compared
+	stagingSchema, stagingName := opts.stagingRef(tableName)
+	stagingTable := qualifiedName(stagingSchema, stagingName)
+	stagingSelect := columns
+	if opts.DevFile == nil && opts.StagingFile == nil {
+		var stagingColumns []string
+		err = stagingDB.Raw("SELECT column_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ?",
+			stagingSchema, stagingName).Scan(&stagingColumns).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get columns for staging table %s: %w", stagingTable, err)
+		}
+
+		var shared []string
+		stagingSelect = nil
+		for _, col := range columns {
+			stagingCol := opts.Mappings.stagingColumn(tableName, col)
+			if !containsString(stagingColumns, stagingCol) {
+				log.Printf("Column %s of table %s is not in staging table %s - not compared", stagingCol, tableName, stagingTable)
+				continue
+			}
+			shared = append(shared, col)
+			if stagingCol != col {
+				// Read the staging column under its dev name so the rows line up
+				stagingSelect = append(stagingSelect, fmt.Sprintf("%s AS %s", stagingCol, col))
+			} else {
+				stagingSelect = append(stagingSelect, col)
+			}
+		}
+		if len(shared) == 0 {
+			return nil, fmt.Errorf("tables %s (dev) and %s (staging) have no columns in common", tableName, stagingTable)
+		}
+		columns = shared
+	}
+
+	// A primary key that isn't compared (not in the master-data file or not in
+	// staging) can't be used for matching
+	for _, pk := range primaryKeys {
+		if _, ok := columnTypes[pk]; ok && !containsString(columns, pk) {
+			log.Printf("Primary key column %s of table %s is not compared; identifying keys from the compared columns", pk, tableName)
+			primaryKeys = tryIdentifyKeyColumns(columns)
+			break
+		}
+	}
+
 	if len(primaryKeys) == 0 {
 		// Special case for role_permissions table and similar relationship tables
 		if tableName == "role_permissions" || strings.HasSuffix(tableName, "_permissions") {
@@ -310,13 +427,55 @@ func compareTable(devDB, stagingDB *gorm.DB, tableName string) (map[string]inter
 		}
 	} // Count rows in both databases
 	var devCount, stagingCount int64
+	var devFileData, stagingFileData []map[string]interface{}
 
-	if err := devDB.Table(tableName).Count(&devCount).Error; err != nil {
+	// Soft-deleted rows can be left out of the counts and the comparison
+	var devWhere, stagingWhere string
+	if opts.SoftDelete.excludes() {
+		devWhere, err = opts.SoftDelete.liveCondition(columns, columnTypes, func(col string) string { return col })
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", tableName, err)
+		}
+		stagingWhere, err = opts.SoftDelete.liveCondition(columns, columnTypes, func(col string) string {
+			return opts.Mappings.stagingColumn(tableName, col)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", tableName, err)
+		}
+	}
+
+	if opts.DevFile != nil {
+		if devFileData, err = opts.DevFile.readTable(tableName, columns, columnTypes); err != nil {
+			return nil, fmt.Errorf("failed to read dev master-data for table %s: %w", tableName, err)
+		}
+		if opts.SoftDelete.excludes() {
+			devFileData = opts.SoftDelete.liveRows(devFileData)
+		}
+		devCount = int64(len(devFileData))
+	} else if err := whereCondition(devDB.Table(devTable), devWhere).Count(&devCount).Error; err != nil {
 		return nil, fmt.Errorf("failed to count rows in dev table %s: %w", tableName, err)
 	}
 
-	if err := stagingDB.Table(tableName).Count(&stagingCount).Error; err != nil {
-		return nil, fmt.Errorf("failed to count rows in staging table %s: %w", tableName, err)
+	if opts.StagingFile != nil {
+		if stagingFileData, err = opts.StagingFile.readTable(tableName, columns, columnTypes); err != nil {
+			return nil, fmt.Errorf("failed to read staging master-data for table %s: %w", tableName, err)
+		}
+		if opts.SoftDelete.excludes() {
+			stagingFileData = opts.SoftDelete.liveRows(stagingFileData)
+		}
+		stagingCount = int64(len(stagingFileData))
+	} else if err := whereCondition(stagingDB.Table(stagingTable), stagingWhere).Count(&stagingCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count rows in staging table %s: %w", stagingTable, err)
+	}
+
+	// With -mode=sample only the rows whose key hash falls in the sample are
+	// read; both sides hash the same keys, so they pick the same rows
+	var devSample, stagingSample string
+	if opts.SampleRate > 0 {
+		devSample = sampleCondition(primaryKeys, opts.SampleRate, opts.SampleSalt, func(col string) string { return col })
+		stagingSample = sampleCondition(primaryKeys, opts.SampleRate, opts.SampleSalt, func(col string) string {
+			return opts.Mappings.stagingColumn(tableName, col)
+		})
 	}
 
 	// Select columns joined with commas for the query
@@ -324,37 +483,91 @@ func compareTable(devDB, stagingDB *gorm.DB, tableName string) (map[string]inter
 
 	// Prepare data structures
 	var devData, stagingData []map[string]interface{}
-	var differences []map[string]interface{}
-	var onlyInDev []map[string]interface{}
-	var onlyInStaging []map[string]interface{}
 
 	// Get ALL data from both tables
 	// For real master data tables, this should be fine as they typically don't have massive amounts of data
-	// But we'll limit to 1000 rows just in case
-	if err := devDB.Raw(fmt.Sprintf("SELECT %s FROM %s LIMIT 1000", columnsStr, tableName)).Scan(&devData).Error; err != nil {
+	// But we'll limit to 1000 rows just in case, except for a sample (see selectRowsSQL)
+	limitRows := opts.SampleRate == 0
+	if opts.DevFile != nil {
+		devData = devFileData
+		log.Printf("Read %d rows for table %s from dev master-data file", len(devData), tableName)
+	} else if err := devDB.Raw(selectRowsSQL(columnsStr, devTable, andConditions(devWhere, devSample), limitRows)).Scan(&devData).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch data from dev table %s: %w", tableName, err)
 	} else {
 		log.Printf("Retrieved %d rows from dev table %s", len(devData), tableName)
 		// If it's a small number of rows, log them for debugging
-		if tableName == "role_permissions" && len(devData) <= 10 {
+		if tableName == "role_permissions" && len(devData) <= 10 && opts.Masks == nil {
 			log.Printf("Dev data for %s: %+v", tableName, devData)
 		}
 	}
 
-	if err := stagingDB.Raw(fmt.Sprintf("SELECT %s FROM %s LIMIT 1000", columnsStr, tableName)).Scan(&stagingData).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch data from staging table %s: %w", tableName, err)
+	if opts.StagingFile != nil {
+		stagingData = stagingFileData
+		log.Printf("Read %d rows for table %s from staging master-data file", len(stagingData), tableName)
+	} else if err := stagingDB.Raw(selectRowsSQL(strings.Join(stagingSelect, ", "), stagingTable, andConditions(stagingWhere, stagingSample), limitRows)).Scan(&stagingData).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch data from staging table %s: %w", stagingTable, err)
 	} else {
-		log.Printf("Retrieved %d rows from staging table %s", len(stagingData), tableName)
+		log.Printf("Retrieved %d rows from staging table %s", len(stagingData), stagingTable)
 		// If it's a small number of rows, log them for debugging
-		if tableName == "role_permissions" && len(stagingData) <= 10 {
+		if tableName == "role_permissions" && len(stagingData) <= 10 && opts.Masks == nil {
 			log.Printf("Staging data for %s: %+v", tableName, stagingData)
 		}
 	}
 
+	result := diffRows(tableName, columns, columnTypes, primaryKeys, devData, stagingData, devCount, stagingCount, opts)
+	if opts.SampleRate > 0 {
+		result["sample_rate"] = opts.SampleRate
+	}
+	return result, nil
+}
+
+// Function to build the query reading the rows of a table. A full
+// comparison reads at most 1000 rows; a sample is read whole, since an
+// unordered LIMIT would cut it at a different point on each side and turn
+// the rows cut off into false "only in" rows.
+func selectRowsSQL(columns, table, condition string, limit bool) string {
+	query := fmt.Sprintf("SELECT %s FROM %s%s", columns, table, whereClause(condition))
+	if limit {
+		query += " LIMIT 1000"
+	}
+	return query
+}
+
+// Helper function to add an optional condition to a query
+func whereCondition(db *gorm.DB, condition string) *gorm.DB {
+	if condition == "" {
+		return db
+	}
+	return db.Where(condition)
+}
+
+// Helper function to format an optional condition as a WHERE clause
+func whereClause(condition string) string {
+	if condition == "" {
+		return ""
+	}
+	return " WHERE " + condition
+}
+
+// Function to match the rows of both sides by their key columns and collect
+// value differences and rows that exist on one side only. tableName identifies
+// the table (or query) in the result and in normalizer patterns.
+func diffRows(tableName string, columns []string, columnTypes map[string]string, primaryKeys []string, devData, stagingData []map[string]interface{}, devCount, stagingCount int64, opts CompareOptions) map[string]interface{} {
+	var differences []map[string]interface{}
+	var changedRecords []map[string]interface{}
+	var onlyInDev []map[string]interface{}
+	var onlyInStaging []map[string]interface{}
+	var deletedInDev, deletedInStaging []map[string]interface{}
+
 	// Create maps for easy lookup by primary key
 	devDataMap := make(map[string]map[string]interface{})
 	stagingDataMap := make(map[string]map[string]interface{})
 
+	// Normalized copies of the rows, used for keys and value comparison while
+	// the original values are kept for the report
+	devNormMap := make(map[string]map[string]interface{})
+	stagingNormMap := make(map[string]map[string]interface{})
+
 	// Use static variable to track which tables we've already logged information for
 	// This avoids excessive repeated log messages
 	var loggedRelationshipTables = make(map[string]bool)
@@ -424,19 +637,39 @@ func compareTable(devDB, stagingDB *gorm.DB, tableName string) (map[string]inter
 		return strings.Join(keyParts, "|")
 	}
 
+	// Masked columns are shown masked everywhere, the key included; matching
+	// and comparison use the normalized original values
+	keyLabels := make(map[string]string)
+	keyLabel := func(key string) string {
+		if label, ok := keyLabels[key]; ok {
+			return label
+		}
+		return key
+	}
+
 	// Populate maps
 	for _, row := range devData {
-		key := makeKey(row, primaryKeys)
-		devDataMap[key] = row
+		normRow := opts.Normalizers.normalizeRow(tableName, row)
+		key := makeKey(normRow, primaryKeys)
+		devDataMap[key] = opts.Masks.maskRow(tableName, row)
+		devNormMap[key] = normRow
+		if opts.Masks != nil {
+			keyLabels[key] = makeKey(opts.Masks.maskRow(tableName, normRow), primaryKeys)
+		}
 	}
 
 	for _, row := range stagingData {
-		key := makeKey(row, primaryKeys)
-		stagingDataMap[key] = row
+		normRow := opts.Normalizers.normalizeRow(tableName, row)
+		key := makeKey(normRow, primaryKeys)
+		stagingDataMap[key] = opts.Masks.maskRow(tableName, row)
+		stagingNormMap[key] = normRow
+		if opts.Masks != nil {
+			keyLabels[key] = makeKey(opts.Masks.maskRow(tableName, normRow), primaryKeys)
+		}
 	}
 
 	// Find differences and records that exist only in one environment
-	if tableName == "role_permissions" {
+	if tableName == "role_permissions" && opts.Masks == nil {
 		log.Printf("Analyzing differences in role_permissions table with %d dev rows and %d staging rows",
 			len(devDataMap), len(stagingDataMap))
 		// Log a few sample keys to debug
@@ -460,22 +693,49 @@ func compareTable(devDB, stagingDB *gorm.DB, tableName string) (map[string]inter
 		}
 	}
 
+	matchedRows := 0
 	for key, devRow := range devDataMap {
 		if stagingRow, exists := stagingDataMap[key]; exists {
 			// Record exists in both - check for differences in values
+			matchedRows++
 			diffRow := make(map[string]interface{})
 			hasDiff := false
+			var changedColumns []string
+			var rowDifferences []map[string]interface{}
+
+			devNorm := devNormMap[key]
+			stagingNorm := stagingNormMap[key]
 
 			for _, col := range columns {
 				devVal := devRow[col]
 				stagingVal := stagingRow[col]
 
-				// Simple string comparison - may need to be enhanced for specific data types
-				if fmt.Sprintf("%v", devVal) != fmt.Sprintf("%v", stagingVal) {
-					diffRow["key"] = key
+				// Simple string comparison of the normalized values - may need to be enhanced for specific data types
+				isDifferent := fmt.Sprintf("%v", devNorm[col]) != fmt.Sprintf("%v", stagingNorm[col])
+
+				// Numbers are compared by value, whatever their representation
+				// (12.50 as text from a file, 12.5 from the database)
+				if isDifferent && isNumericType(columnTypes[col]) && numericEqual(devNorm[col], stagingNorm[col]) {
+					isDifferent = false
+				}
+
+				// JSON columns are compared structurally, ignoring key order and formatting
+				var jsonPaths []jsonPathDiff
+				if isDifferent && isJSONType(columnTypes[col]) {
+					if paths, ok := diffJSONValues(devNorm[col], stagingNorm[col]); ok {
+						jsonPaths = paths
+						isDifferent = len(paths) > 0
+					}
+				}
+
+				if isDifferent {
+					diffRow["key"] = keyLabel(key)
 					diffRow["column"] = col
 					diffRow["dev_value"] = devVal
 					diffRow["staging_value"] = stagingVal
+					if jsonPaths != nil && !opts.Masks.masks(tableName, col) {
+						diffRow["json_paths"] = jsonPaths
+					}
 
 					// For composite key tables like role_permissions, add key columns for clarity
 					for _, pkCol := range primaryKeys {
@@ -484,22 +744,58 @@ func compareTable(devDB, stagingDB *gorm.DB, tableName string) (map[string]inter
 						}
 					}
 
-					differences = append(differences, diffRow)
+					rowDifferences = append(rowDifferences, diffRow)
+					changedColumns = append(changedColumns, col)
 					hasDiff = true
 					diffRow = make(map[string]interface{}) // Create a new map for next difference
 				}
 			}
 
 			if hasDiff {
+				// Keep the whole record as well for the record-level view
+				changedRecord := map[string]interface{}{
+					"key":             keyLabel(key),
+					"dev_row":         devRow,
+					"staging_row":     stagingRow,
+					"changed_columns": changedColumns,
+				}
 				// Add identifiers for easier reading
 				for _, pkCol := range primaryKeys {
-					diffRow["pk_"+pkCol] = devRow[pkCol]
+					changedRecord["pk_"+pkCol] = devRow[pkCol]
+				}
+
+				// A row soft-deleted on one side only is its own category
+				// rather than value differences on the soft-delete columns.
+				// Differences in its other columns are still reported.
+				devDeleted, stagingDeleted := opts.SoftDelete.deleted(devNorm), opts.SoftDelete.deleted(stagingNorm)
+				if opts.SoftDelete.reports() && devDeleted != stagingDeleted {
+					if stagingDeleted {
+						deletedInStaging = append(deletedInStaging, changedRecord)
+					} else {
+						deletedInDev = append(deletedInDev, changedRecord)
+					}
+
+					var otherDifferences []map[string]interface{}
+					var otherColumns []string
+					for _, d := range rowDifferences {
+						if col := d["column"].(string); !opts.SoftDelete.isColumn(col) {
+							otherDifferences = append(otherDifferences, d)
+							otherColumns = append(otherColumns, col)
+						}
+					}
+					rowDifferences = otherDifferences
+					changedRecord = maps.Clone(changedRecord)
+					changedRecord["changed_columns"] = otherColumns
+				}
+				if len(rowDifferences) > 0 {
+					differences = append(differences, rowDifferences...)
+					changedRecords = append(changedRecords, changedRecord)
 				}
 			}
 		} else {
 			// Record only exists in dev
 			onlyInDev = append(onlyInDev, devRow)
-			if tableName == "role_permissions" {
+			if tableName == "role_permissions" && opts.Masks == nil {
 				log.Printf("Found record only in dev with key: %s, data: %+v", key, devRow)
 			}
 		}
@@ -509,393 +805,582 @@ func compareTable(devDB, stagingDB *gorm.DB, tableName string) (map[string]inter
 	for key, stagingRow := range stagingDataMap {
 		if _, exists := devDataMap[key]; !exists {
 			onlyInStaging = append(onlyInStaging, stagingRow)
-			if tableName == "role_permissions" {
+			if tableName == "role_permissions" && opts.Masks == nil {
 				log.Printf("Found record only in staging with key: %s, data: %+v", key, stagingRow)
 			}
 		}
 	}
 
+	// Aggregate the differences per column to show systematic drift
+	columnStats := computeColumnStats(columns, devData, stagingData, differences, matchedRows)
+
 	// Return comparison result
 	result := map[string]interface{}{
 		"table_name":      tableName,
 		"columns":         columns,
+		"column_types":    columnTypes,
 		"primary_keys":    primaryKeys,
 		"has_primary_key": len(primaryKeys) < len(columns), // true if we're not using all columns as key
 		"using_composite": len(primaryKeys) > 1,            // true if using multiple columns as key
 		"dev_count":       devCount,
 		"staging_count":   stagingCount,
 		"count_diff":      devCount - stagingCount,
-		"dev_data":        devData,
-		"staging_data":    stagingData,
+		"dev_data":        opts.Masks.maskRows(tableName, devData),
+		"staging_data":    opts.Masks.maskRows(tableName, stagingData),
 		"differences":     differences,
+		"changed_records": changedRecords,
+		"matched_rows":    matchedRows,
+		"column_stats":    columnStats,
 		"only_in_dev":     onlyInDev,
 		"only_in_staging": onlyInStaging,
 	}
+	if opts.SoftDelete.reports() {
+		result["deleted_in_dev"] = deletedInDev
+		result["deleted_in_staging"] = deletedInStaging
+	}
 
-	return result, nil
+	return result
 }
 
-// Function to export comparison results to Excel
-func exportToExcel(results []map[string]interface{}, filename string) error {
-	f := excelize.NewFile()
-
-	// Create summary sheet
-	summarySheet := "Summary"
-	f.SetSheetName("Sheet1", summarySheet)
-
-	// Set summary sheet headers
-	headers := []string{"Table Name", "PK Type", "Dev Count", "Staging Count", "Count Difference", "Value Differences", "Only in Dev", "Only in Staging"}
-	for i, header := range headers {
-		cell := fmt.Sprintf("%c%d", 'A'+i, 1)
-		f.SetCellValue(summarySheet, cell, header)
+func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		runApply(os.Args[2:])
+		return
 	}
 
-	// Apply header style
-	style, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{Bold: true},
-		Fill: excelize.Fill{Type: "pattern", Color: []string{"#DDEBF7"}, Pattern: 1},
-		Border: []excelize.Border{
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-	// Apply header style to first row
-	f.SetRowStyle(summarySheet, 1, 1, style)
-
-	// Set column widths
-	f.SetColWidth(summarySheet, "A", "A", 20)
-	f.SetColWidth(summarySheet, "B", "B", 18) // PK Type column
-	f.SetColWidth(summarySheet, "C", "H", 15)
-
-	// Fill summary data
-	for i, result := range results {
-		rowNum := i + 2
-		tableName := result["table_name"].(string)
-		differences := result["differences"].([]map[string]interface{})
-		onlyInDev := result["only_in_dev"].([]map[string]interface{})
-		onlyInStaging := result["only_in_staging"].([]map[string]interface{})
-		primaryKeys := result["primary_keys"].([]string)
-		hasPrimaryKey := result["has_primary_key"].(bool)
-		usingComposite := result["using_composite"].(bool)
-
-		// Determine what kind of key is being used for comparison
-		var keyTypeText string
-		if !hasPrimaryKey {
-			keyTypeText = "All Columns"
-		} else if usingComposite {
-			keyTypeText = fmt.Sprintf("Composite (%d cols)", len(primaryKeys))
-		} else {
-			keyTypeText = primaryKeys[0] // Single column PK
+	// Define command-line flags
+	listTablesFlag := flag.Bool("list", false, "List available tables (or queries with -mode=query) and exit")
+	modeFlag := flag.String("mode", "table", "What is compared: 'table' (table data), 'count' (row counts only), 'sample' (a sample of the rows, see -sample-rate), 'query' (the result of -query or of the named queries in -queries) or 'objects' (only the objects of -objects)")
+	objectsFlag := flag.String("objects", "", "Comma-separated database objects compared besides the tables: sequences, enums, domains, views, matviews, functions, triggers, policies, extensions or all (default with -mode=objects: all)")
+	countMethodFlag := flag.String("count-method", "exact", "How -mode=count counts rows: 'exact' (COUNT(*)) or 'estimate' (pg_class.reltuples, as of the last ANALYZE)")
+	sampleRateFlag := flag.String("sample-rate", "1%", "Share of rows compared with -mode=sample, as a percentage (e.g. '1%') or a fraction")
+	sampleMethodFlag := flag.String("sample-method", "hash", "How -mode=sample picks rows: 'hash' (the same rows every run, by key hash) or 'random' (other rows every run)")
+	queryFlag := flag.String("query", "", "SELECT compared with -mode=query (needs -key)")
+	keyFlag := flag.String("key", "", "Comma-separated key columns matching the rows of -query")
+	queriesFlag := flag.String("queries", "", "YAML file with named queries for -mode=query")
+	queryNameFlag := flag.String("query-name", "", "Comma-separated names of the queries in -queries to compare (default: all)")
+	specificTablesFlag := flag.String("tables", "", "Comma-separated list of specific tables to compare")
+	patternFlag := flag.String("pattern", "", "Pattern to filter table names (e.g. 'user' will match 'users', 'user_roles', etc.)")
+	masterTablesFlag := flag.Bool("master", true, "Only include master tables in comparison")
+	var includeFlag, excludeFlag tablePatternFlag
+	flag.Var(&includeFlag, "include", "Only compare tables matching this glob (or 're:' regex); repeatable")
+	flag.Var(&excludeFlag, "exclude", "Skip tables matching this glob (or 're:' regex); repeatable")
+	outputFlag := flag.String("output", "", "Output file name (default: auto-generated with timestamp)")
+	diffViewFlag := flag.String("diff-view", "column", "How value differences are shown: 'column' (one row per changed column), 'record' (one row per changed record) or 'both'")
+	configFlag := flag.String("config", "", "Path to a YAML file with comparison rules (normalizers, ...)")
+	normalizeFlag := flag.String("normalize", "", "Comma-separated normalizers applied to every column (e.g. 'trim,null_empty')")
+	maskFlag := flag.String("mask", "", "Comma-separated column patterns whose values are hashed in every output (e.g. 'password*,*email*,users.phone')")
+	devFileFlag := flag.String("dev-file", "", "Use a master-data file (.xlsx, .csv or a directory of .csv files) instead of the dev database")
+	consistentFlag := flag.Bool("consistent", true, "Read each database inside one REPEATABLE READ, READ ONLY transaction so all tables reflect the same point in time")
+	checkpointFlag := flag.String("checkpoint", defaultCheckpointFile, "File the progress is saved to after each table, used by -resume (empty to disable)")
+	resumeFlag := flag.Bool("resume", false, "Skip tables already compared according to the checkpoint file and merge their stored results into the report")
+	profiles := newProfileFlags(flag.CommandLine)
+	confirmThresholdFlag := flag.Int("confirm-threshold", 10, "Ask for confirmation when more than this many tables are selected (0 never asks)")
+	confirm := newConfirmer(flag.CommandLine)
+	timeoutFlag := flag.Duration("timeout", 0, "Stop comparing after this duration (e.g. '30m') and write a partial report; 0 means no limit")
+	workersFlag := flag.Int("workers", 1, "Number of tables compared in parallel (workers share the snapshot of each database)")
+	softDeleteFlag := flag.String("soft-delete", "", "Soft-deleted rows (is_deleted/deleted_at): 'off' (compare like other rows), 'exclude' (leave out) or 'report' (report rows deleted on one side only separately); default from -config, else off")
+	sourceSchemaFlag := flag.String("source-schema", "public", "Schema the dev tables are read from")
+	targetSchemaFlag := flag.String("target-schema", "public", "Schema the staging tables are read from")
+	sameDatabaseFlag := flag.Bool("same-database", false, "Read the staging side from the dev connection, to compare schemas or table copies within one database")
+	mapTables := make(map[string]string)
+	flag.Func("map-table", "Compare a dev table with a differently named staging table, as 'dev_table=[schema.]staging_table'; repeatable", func(value string) error {
+		devTable, stagingTable, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(devTable) == "" || strings.TrimSpace(stagingTable) == "" {
+			return fmt.Errorf("expected dev_table=[schema.]staging_table")
 		}
+		mapTables[strings.TrimSpace(devTable)] = strings.TrimSpace(stagingTable)
+		return nil
+	})
+	stagingFileFlag := flag.String("staging-file", "", "Use a master-data file (.xlsx, .csv or a directory of .csv files) instead of the staging database")
 
-		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", rowNum), tableName)
-		f.SetCellValue(summarySheet, fmt.Sprintf("B%d", rowNum), keyTypeText)
-		f.SetCellValue(summarySheet, fmt.Sprintf("C%d", rowNum), result["dev_count"])
-		f.SetCellValue(summarySheet, fmt.Sprintf("D%d", rowNum), result["staging_count"])
-		f.SetCellValue(summarySheet, fmt.Sprintf("E%d", rowNum), result["count_diff"])
-		f.SetCellValue(summarySheet, fmt.Sprintf("F%d", rowNum), len(differences))
-		f.SetCellValue(summarySheet, fmt.Sprintf("G%d", rowNum), len(onlyInDev))
-		f.SetCellValue(summarySheet, fmt.Sprintf("H%d", rowNum), len(onlyInStaging))
+	// Parse command-line arguments
+	flag.Parse()
+	startedAt := time.Now()
 
-		// Add color to rows with differences
-		if len(differences) > 0 || len(onlyInDev) > 0 || len(onlyInStaging) > 0 {
-			diffStyle, _ := f.NewStyle(&excelize.Style{
-				Fill: excelize.Fill{Type: "pattern", Color: []string{"#FFEB9C"}, Pattern: 1},
-			})
-			f.SetRowStyle(summarySheet, rowNum, rowNum, diffStyle)
-		}
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using system environment variables")
+	}
 
-		// Create detailed sheets for each table
-		createDetailedSheets(f, result, tableName)
+	switch *diffViewFlag {
+	case "column", "record", "both":
+	default:
+		log.Fatalf("Invalid -diff-view %q: expected 'column', 'record' or 'both'", *diffViewFlag)
 	}
 
-	// Save the Excel file
-	if err := f.SaveAs(filename); err != nil {
-		return fmt.Errorf("failed to save Excel file: %w", err)
+	// Load comparison rules
+	compareConfig, err := loadCompareConfig(*configFlag)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	return nil
-}
+	normalizeSpec := compareConfig.Normalize
+	if *normalizeFlag != "" {
+		if normalizeSpec == nil {
+			normalizeSpec = make(map[string][]string)
+		}
+		normalizeSpec["*"] = append(normalizeSpec["*"], strings.Split(*normalizeFlag, ",")...)
+	}
 
-// Helper function to create detailed sheets for each table comparison
-func createDetailedSheets(f *excelize.File, result map[string]interface{}, tableName string) {
-	columns := result["columns"].([]string)
-	primaryKeys := result["primary_keys"].([]string)
-	differences := result["differences"].([]map[string]interface{})
-	onlyInDev := result["only_in_dev"].([]map[string]interface{})
-	onlyInStaging := result["only_in_staging"].([]map[string]interface{})
+	normalizers, err := parseNormalizers(normalizeSpec)
+	if err != nil {
+		log.Fatalf("Invalid normalizer configuration: %v", err)
+	}
 
-	// Create a differences sheet
-	if len(differences) > 0 {
-		diffSheet := fmt.Sprintf("%s_Diff", tableName)
-		if len(diffSheet) > 31 {
-			diffSheet = diffSheet[:31]
-		}
-		f.NewSheet(diffSheet)
+	classifiers, err := newTableClassifiers(compareConfig.MasterTables)
+	if err != nil {
+		log.Fatalf("Invalid master table configuration: %v", err)
+	}
 
-		// Headers for diff sheet
-		diffHeaders := []string{"Primary Key"}
-		for _, pk := range primaryKeys {
-			diffHeaders = append(diffHeaders, pk)
+	// -map-table adds to the table mappings of the config file
+	if len(mapTables) > 0 {
+		if compareConfig.Mappings == nil {
+			compareConfig.Mappings = &MappingConfig{}
 		}
-		diffHeaders = append(diffHeaders, "Column", "Dev Value", "Staging Value")
-
-		for i, header := range diffHeaders {
-			cell := fmt.Sprintf("%c%d", 'A'+i, 1)
-			f.SetCellValue(diffSheet, cell, header)
+		if compareConfig.Mappings.Tables == nil {
+			compareConfig.Mappings.Tables = make(map[string]string)
 		}
+		for devTable, stagingTable := range mapTables {
+			compareConfig.Mappings.Tables[devTable] = stagingTable
+		}
+	}
 
-		// Apply header style
-		style, _ := f.NewStyle(&excelize.Style{
-			Font: &excelize.Font{Bold: true},
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"#DDEBF7"}, Pattern: 1},
-		})
-		f.SetRowStyle(diffSheet, 1, 1, style)
+	if err := compareConfig.Mappings.validate(); err != nil {
+		log.Fatalf("Invalid mapping configuration: %v", err)
+	}
 
-		// Set column widths
-		for i := 0; i < len(diffHeaders); i++ {
-			colName := fmt.Sprintf("%c", 'A'+i)
-			f.SetColWidth(diffSheet, colName, colName, 18)
+	maskSpec := compareConfig.Mask
+	if *maskFlag != "" {
+		if maskSpec == nil {
+			maskSpec = make(map[string]string)
 		}
+		for _, pattern := range strings.Split(*maskFlag, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				maskSpec[pattern] = "hash"
+			}
+		}
+	}
 
-		// Fill differences data
-		for i, diff := range differences {
-			rowNum := i + 2
-
-			// Key column
-			f.SetCellValue(diffSheet, fmt.Sprintf("A%d", rowNum), diff["key"])
+	masks, err := parseMasks(maskSpec, os.Getenv("MASK_KEY"))
+	if err != nil {
+		log.Fatalf("Invalid mask configuration: %v", err)
+	}
 
-			// Primary key columns
-			colOffset := 1
-			for j, pk := range primaryKeys {
-				if pkVal, ok := diff["pk_"+pk]; ok {
-					f.SetCellValue(diffSheet, fmt.Sprintf("%c%d", 'A'+colOffset+j, rowNum), pkVal)
-				}
-			}
-			colOffset += len(primaryKeys)
+	softDelete, err := newSoftDeleteConfig(compareConfig.SoftDelete, *softDeleteFlag)
+	if err != nil {
+		log.Fatalf("Invalid soft-delete configuration: %v", err)
+	}
 
-			// Difference details
-			f.SetCellValue(diffSheet, fmt.Sprintf("%c%d", 'A'+colOffset, rowNum), diff["column"])
-			f.SetCellValue(diffSheet, fmt.Sprintf("%c%d", 'A'+colOffset+1, rowNum), diff["dev_value"])
-			f.SetCellValue(diffSheet, fmt.Sprintf("%c%d", 'A'+colOffset+2, rowNum), diff["staging_value"])
+	compareOpts := CompareOptions{
+		Normalizers: normalizers,
+		Mappings:    compareConfig.Mappings,
+		SoftDelete:  softDelete,
+		Masks:       masks,
 
-			// Add background color for easy visibility
-			diffStyle, _ := f.NewStyle(&excelize.Style{
-				Fill: excelize.Fill{Type: "pattern", Color: []string{"#FFEB9C"}, Pattern: 1},
-			})
-			f.SetRowStyle(diffSheet, rowNum, rowNum, diffStyle)
-		}
+		DevSchema:     *sourceSchemaFlag,
+		StagingSchema: *targetSchemaFlag,
 	}
 
-	// Create a sheet for records only in dev
-	if len(onlyInDev) > 0 {
-		devOnlySheet := fmt.Sprintf("%s_OnlyInDev", tableName)
-		if len(devOnlySheet) > 31 {
-			devOnlySheet = devOnlySheet[:31]
+	// Load master-data files used in place of a database
+	if *devFileFlag != "" && *stagingFileFlag != "" {
+		log.Fatalf("-dev-file and -staging-file can't be combined: at least one side must be a database")
+	}
+	if *devFileFlag != "" {
+		if compareOpts.DevFile, err = openMasterDataFile(*devFileFlag); err != nil {
+			log.Fatalf("Failed to load dev master-data file: %v", err)
 		}
-		f.NewSheet(devOnlySheet)
-
-		// Headers
-		for i, col := range columns {
-			cell := fmt.Sprintf("%c%d", 'A'+i, 1)
-			f.SetCellValue(devOnlySheet, cell, col)
+	}
+	if *stagingFileFlag != "" {
+		if compareOpts.StagingFile, err = openMasterDataFile(*stagingFileFlag); err != nil {
+			log.Fatalf("Failed to load staging master-data file: %v", err)
 		}
+	}
 
-		// Style headers
-		style, _ := f.NewStyle(&excelize.Style{
-			Font: &excelize.Font{Bold: true},
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"#DDEBF7"}, Pattern: 1},
-		})
-		f.SetRowStyle(devOnlySheet, 1, 1, style)
+	if compareOpts.Mappings != nil && (compareOpts.DevFile != nil || compareOpts.StagingFile != nil) {
+		log.Fatalf("Table and column mappings apply between two databases; they can't be combined with -dev-file or -staging-file")
+	}
 
-		// Data
-		for rowIdx, row := range onlyInDev {
-			for colIdx, col := range columns {
-				cell := fmt.Sprintf("%c%d", 'A'+colIdx, rowIdx+2)
-				f.SetCellValue(devOnlySheet, cell, row[col])
-			}
+	// Query mode compares the result of SELECTs instead of tables
+	var queries []namedQuery
+	var queryByName map[string]namedQuery
+	switch *modeFlag {
+	case "table", "count", "sample":
+		if *queryFlag != "" || *queriesFlag != "" {
+			log.Fatalf("-query and -queries need -mode=query")
 		}
-
-		// Set column widths
-		for i := 0; i < len(columns); i++ {
-			colName := fmt.Sprintf("%c", 'A'+i)
-			f.SetColWidth(devOnlySheet, colName, colName, 15)
+		if *modeFlag == "count" && *countMethodFlag != "exact" && *countMethodFlag != "estimate" {
+			log.Fatalf("Invalid -count-method %q: expected 'exact' or 'estimate'", *countMethodFlag)
 		}
-	}
-
-	// Create a sheet for records only in staging
-	if len(onlyInStaging) > 0 {
-		stagingOnlySheet := fmt.Sprintf("%s_OnlyInStaging", tableName)
-		if len(stagingOnlySheet) > 31 {
-			stagingOnlySheet = stagingOnlySheet[:31]
+		if *modeFlag == "sample" {
+			if compareOpts.DevFile != nil || compareOpts.StagingFile != nil {
+				log.Fatalf("-mode=sample selects rows in the database; it can't be combined with -dev-file or -staging-file")
+			}
+			if compareOpts.SampleRate, err = parseSampleRate(*sampleRateFlag); err != nil {
+				log.Fatalf("Invalid -sample-rate: %v", err)
+			}
+			if compareOpts.SampleSalt, err = sampleSalt(*sampleMethodFlag); err != nil {
+				log.Fatalf("Invalid -sample-method: %v", err)
+			}
 		}
-		f.NewSheet(stagingOnlySheet)
-
-		// Headers
-		for i, col := range columns {
-			cell := fmt.Sprintf("%c%d", 'A'+i, 1)
-			f.SetCellValue(stagingOnlySheet, cell, col)
+	case "query":
+		if compareOpts.DevFile != nil || compareOpts.StagingFile != nil {
+			log.Fatalf("-mode=query compares two databases; -dev-file and -staging-file can't be used")
 		}
-
-		// Style headers
-		style, _ := f.NewStyle(&excelize.Style{
-			Font: &excelize.Font{Bold: true},
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"#DDEBF7"}, Pattern: 1},
-		})
-		f.SetRowStyle(stagingOnlySheet, 1, 1, style)
-
-		// Data
-		for rowIdx, row := range onlyInStaging {
-			for colIdx, col := range columns {
-				cell := fmt.Sprintf("%c%d", 'A'+colIdx, rowIdx+2)
-				f.SetCellValue(stagingOnlySheet, cell, row[col])
+		if *queryFlag != "" {
+			query := namedQuery{Name: inlineQueryName, SQL: *queryFlag}
+			for _, key := range strings.Split(*keyFlag, ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					query.Key = append(query.Key, key)
+				}
 			}
+			if err := query.validate(); err != nil {
+				log.Fatalf("Invalid -query: %v (use -key to declare the key columns)", err)
+			}
+			queries = append(queries, query)
 		}
-
-		// Set column widths
-		for i := 0; i < len(columns); i++ {
-			colName := fmt.Sprintf("%c", 'A'+i)
-			f.SetColWidth(stagingOnlySheet, colName, colName, 15)
+		if *queriesFlag != "" {
+			var names []string
+			if *queryNameFlag != "" {
+				names = strings.Split(*queryNameFlag, ",")
+			}
+			named, err := loadQueries(*queriesFlag, names)
+			if err != nil {
+				log.Fatalf("Failed to load queries: %v", err)
+			}
+			queries = append(queries, named...)
+		}
+		if len(queries) == 0 {
+			log.Fatalf("-mode=query needs -query or -queries")
+		}
+		if queryByName, err = queriesByName(queries); err != nil {
+			log.Fatalf("Invalid queries: %v", err)
 		}
+	case "objects":
+		if *objectsFlag == "" {
+			*objectsFlag = "all"
+		}
+	default:
+		log.Fatalf("Invalid -mode %q: expected 'table', 'count', 'sample', 'query' or 'objects'", *modeFlag)
 	}
-}
 
-func main() {
-	// Define command-line flags
-	listTablesFlag := flag.Bool("list", false, "List available tables and exit")
-	specificTablesFlag := flag.String("tables", "", "Comma-separated list of specific tables to compare")
-	patternFlag := flag.String("pattern", "", "Pattern to filter table names (e.g. 'user' will match 'users', 'user_roles', etc.)")
-	masterTablesFlag := flag.Bool("master", true, "Only include master tables in comparison")
-	outputFlag := flag.String("output", "", "Output file name (default: auto-generated with timestamp)")
+	// Database objects (sequences, enums, ...) are compared after the tables
+	objectKinds, err := parseObjectKinds(*objectsFlag)
+	if err != nil {
+		log.Fatalf("Invalid -objects: %v", err)
+	}
+	if len(objectKinds) > 0 && (compareOpts.DevFile != nil || compareOpts.StagingFile != nil) {
+		log.Fatalf("-objects compares two databases; -dev-file and -staging-file can't be used")
+	}
 
-	// Parse command-line arguments
-	flag.Parse()
+	// Configure database connections
+	devConfig, stagingConfig, err := profiles.resolve()
+	if err != nil {
+		log.Fatalf("Failed to load database settings: %v", err)
+	}
 
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using system environment variables")
+	// Both sides can be tables of one database, in different schemas or under
+	// different names
+	if *sameDatabaseFlag {
+		if compareOpts.DevFile != nil || compareOpts.StagingFile != nil {
+			log.Fatalf("-same-database can't be combined with -dev-file or -staging-file")
+		}
+		if *profiles.target != "" {
+			log.Fatalf("-same-database reads staging from the dev connection; -target can't be used")
+		}
+		if compareOpts.DevSchema == compareOpts.StagingSchema && compareOpts.Mappings == nil && *modeFlag == "table" {
+			log.Printf("Warning: -same-database without -target-schema or table mappings compares every table with itself")
+		}
+		stagingConfig = devConfig
 	}
 
-	// Configure database connections
-	devConfig := DBConfig{
-		Host:     getEnv("DEV_DB_HOST", "localhost"),
-		Port:     getEnv("DEV_DB_PORT", "5432"),
-		User:     getEnv("DEV_DB_USER", "postgres"),
-		Password: getEnv("DEV_DB_PASSWORD", ""),
-		DBName:   getEnv("DEV_DB_NAME", ""),
+	// Connect to databases (a side backed by a master-data file needs no connection)
+	var devDB, stagingDB *gorm.DB
+	if compareOpts.DevFile == nil {
+		log.Println("Connecting to development database...")
+		devDB, err = connectDB(devConfig)
+		if err != nil {
+			log.Fatalf("Failed to connect to development database: %v", err)
+		}
 	}
 
-	stagingConfig := DBConfig{
-		Host:     getEnv("STAGING_DB_HOST", "localhost"),
-		Port:     getEnv("STAGING_DB_PORT", "5432"),
-		User:     getEnv("STAGING_DB_USER", "postgres"),
-		Password: getEnv("STAGING_DB_PASSWORD", ""),
-		DBName:   getEnv("STAGING_DB_NAME", ""),
+	if *sameDatabaseFlag {
+		stagingDB = devDB
+	} else if compareOpts.StagingFile == nil {
+		log.Println("Connecting to staging database...")
+		stagingDB, err = connectDB(stagingConfig)
+		if err != nil {
+			log.Fatalf("Failed to connect to staging database: %v", err)
+		}
 	}
 
-	// Connect to databases
-	log.Println("Connecting to development database...")
-	devDB, err := connectDB(devConfig)
-	if err != nil {
-		log.Fatalf("Failed to connect to development database: %v", err)
+	if *workersFlag < 1 {
+		log.Fatalf("Invalid -workers %d: must be at least 1", *workersFlag)
 	}
 
-	log.Println("Connecting to staging database...")
-	stagingDB, err := connectDB(stagingConfig)
+	// Take one read snapshot per database for the whole run
+	devReader, err := openSnapshotReader(devDB, *consistentFlag, *workersFlag > 1)
 	if err != nil {
-		log.Fatalf("Failed to connect to staging database: %v", err)
+		log.Fatalf("Failed to open development database snapshot: %v", err)
 	}
+	defer devReader.close()
 
-	// Get tables based on flags
-	var allTables []string
-	var tablesToCompare []string
+	// With -same-database both sides read from the same snapshot
+	stagingReader := devReader
+	if !*sameDatabaseFlag {
+		stagingReader, err = openSnapshotReader(stagingDB, *consistentFlag, *workersFlag > 1)
+		if err != nil {
+			log.Fatalf("Failed to open staging database snapshot: %v", err)
+		}
+		defer stagingReader.close()
+	}
 
-	if *masterTablesFlag {
-		log.Println("Retrieving master data tables from database...")
-		allTables, err = getMasterTables(devDB)
-	} else {
-		log.Println("Retrieving all tables from database...")
-		allTables, err = getAllTables(devDB)
+	// Tables are listed from the database side
+	metaDB, metaSchema := devReader.conn(), compareOpts.DevSchema
+	if metaDB == nil {
+		metaDB, metaSchema = stagingReader.conn(), compareOpts.StagingSchema
 	}
 
-	if err != nil {
-		log.Fatalf("Failed to get tables: %v", err)
+	var tablesToCompare []string
+	compare := func(ctx context.Context, devDB, stagingDB *gorm.DB, tableName string) (map[string]interface{}, error) {
+		return compareTable(ctx, devDB, stagingDB, tableName, compareOpts)
 	}
 
-	// Handle list tables flag - just show tables and exit
-	if *listTablesFlag {
-		fmt.Println("Available tables:")
-		for i, table := range allTables {
-			fmt.Printf("%d. %s\n", i+1, table)
+	if *modeFlag == "query" {
+		if *listTablesFlag {
+			fmt.Println("Queries:")
+			for i, query := range queries {
+				fmt.Printf("%d. %s (key: %s)\n", i+1, query.Name, strings.Join(query.Key, ", "))
+			}
+			return
 		}
-		return
-	}
 
-	// Determine which tables to compare
-	if *specificTablesFlag != "" {
-		// Use specific tables provided in the flag
-		requestedTables := strings.Split(*specificTablesFlag, ",")
-		for _, tableName := range requestedTables {
-			trimmedName := strings.TrimSpace(tableName)
-			// Check if this table exists in the database
-			found := false
-			for _, availableTable := range allTables {
-				if availableTable == trimmedName {
-					found = true
-					tablesToCompare = append(tablesToCompare, trimmedName)
-					break
+		for _, query := range queries {
+			tablesToCompare = append(tablesToCompare, query.Name)
+		}
+		compare = func(ctx context.Context, devDB, stagingDB *gorm.DB, name string) (map[string]interface{}, error) {
+			return compareQuery(ctx, devDB, stagingDB, queryByName[name], compareOpts)
+		}
+		log.Printf("Selected %d queries for comparison: %s", len(tablesToCompare), strings.Join(tablesToCompare, ", "))
+	} else if *modeFlag == "objects" {
+		if *listTablesFlag {
+			fmt.Println("Object kinds:")
+			for i, name := range objectKindNames(objectKinds) {
+				fmt.Printf("%d. %s\n", i+1, name)
+			}
+			return
+		}
+	} else {
+		if *modeFlag == "count" {
+			estimate := *countMethodFlag == "estimate"
+			compare = func(ctx context.Context, devDB, stagingDB *gorm.DB, tableName string) (map[string]interface{}, error) {
+				return countTable(ctx, devDB, stagingDB, tableName, estimate, compareOpts)
+			}
+		}
+
+		// Get tables based on flags
+		var allTables []string
+
+		var classifications []tableClassification
+		if *masterTablesFlag {
+			log.Println("Retrieving master data tables from database...")
+			allTables, classifications, err = getMasterTables(metaDB, metaSchema, classifiers)
+		} else {
+			log.Println("Retrieving all tables from database...")
+			allTables, err = getAllTables(metaDB, metaSchema)
+		}
+
+		if err != nil {
+			log.Fatalf("Failed to get tables: %v", err)
+		}
+
+		// With a master-data file only the tables it contains can be compared
+		dbTables := lowerStrings(allTables)
+		for _, file := range []*masterDataFile{compareOpts.DevFile, compareOpts.StagingFile} {
+			if file == nil {
+				continue
+			}
+			var inFile []string
+			for _, table := range allTables {
+				if file.hasTable(table) {
+					inFile = append(inFile, table)
+				}
+			}
+			for _, name := range file.tableNames() {
+				if !containsString(dbTables, name) {
+					log.Printf("Warning: '%s' in master-data file %s is not a table in the database - skipping", name, file.path)
+				}
+			}
+			allTables = inFile
+
+			// -list shows only the classified tables the file can provide
+			if classifications != nil {
+				classifiedInFile := []tableClassification{}
+				for _, c := range classifications {
+					if file.hasTable(c.Name) {
+						classifiedInFile = append(classifiedInFile, c)
+					}
 				}
+				classifications = classifiedInFile
 			}
+		}
 
-			if !found {
-				log.Printf("Warning: Table '%s' not found in database - skipping", trimmedName)
+		// Handle list tables flag - just show tables and exit. -include/-exclude
+		// narrow the list, so a filter can be checked before running it.
+		if *listTablesFlag {
+			if classifications != nil {
+				// Show every table with the rule that classified it
+				fmt.Println("Tables and their classification:")
+				i := 0
+				for _, c := range classifications {
+					if len(includeExcludeTables([]string{c.Name}, includeFlag, excludeFlag)) == 0 {
+						continue
+					}
+					i++
+					status := "not master"
+					if c.Master {
+						status = "master"
+					}
+					fmt.Printf("%d. %-40s %-10s (%s)\n", i, c.Name, status, c.Reason)
+				}
+				return
+			}
+			fmt.Println("Available tables:")
+			for i, table := range includeExcludeTables(allTables, includeFlag, excludeFlag) {
+				fmt.Printf("%d. %s\n", i+1, table)
 			}
+			return
 		}
-	} else if *patternFlag != "" {
-		// Filter tables by pattern
-		tablesToCompare = filterTables(allTables, *patternFlag)
-	} else {
-		// Use all tables
-		tablesToCompare = allTables
-	}
 
-	if len(tablesToCompare) == 0 {
-		log.Println("No tables selected for comparison. Use -list to see available tables.")
-		return
-	}
+		// Determine which tables to compare
+		if *specificTablesFlag != "" {
+			// Use specific tables provided in the flag
+			requestedTables := strings.Split(*specificTablesFlag, ",")
+			for _, tableName := range requestedTables {
+				trimmedName := strings.TrimSpace(tableName)
+				// Check if this table exists in the database
+				found := false
+				for _, availableTable := range allTables {
+					if availableTable == trimmedName {
+						found = true
+						tablesToCompare = append(tablesToCompare, trimmedName)
+						break
+					}
+				}
 
-	log.Printf("Selected %d tables for comparison: %s", len(tablesToCompare), strings.Join(tablesToCompare, ", "))
+				if !found {
+					log.Printf("Warning: Table '%s' not found in database - skipping", trimmedName)
+				}
+			}
+		} else if *patternFlag != "" {
+			// Filter tables by pattern
+			tablesToCompare = filterTables(allTables, *patternFlag)
+		} else {
+			// Use all tables
+			tablesToCompare = allTables
+		}
 
-	// Ask for confirmation if more than 10 tables are selected
-	if len(tablesToCompare) > 10 {
-		fmt.Printf("You've selected %d tables for comparison. This might take a while. Continue? (y/n): ", len(tablesToCompare))
-		var response string
-		fmt.Scanln(&response)
-		if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
-			log.Println("Operation cancelled by user")
+		// Narrow the selection with -include/-exclude
+		if len(includeFlag) > 0 || len(excludeFlag) > 0 {
+			tablesToCompare = includeExcludeTables(tablesToCompare, includeFlag, excludeFlag)
+		}
+
+		if len(tablesToCompare) == 0 {
+			log.Println("No tables selected for comparison. Use -list to see available tables.")
 			return
 		}
+
+		log.Printf("Selected %d tables for comparison: %s", len(tablesToCompare), strings.Join(tablesToCompare, ", "))
 	}
 
-	// Compare selected tables
-	var results []map[string]interface{}
-	for _, tableName := range tablesToCompare {
-		log.Printf("Comparing table: %s", tableName)
+	// Ask for confirmation if many tables are selected
+	if *confirmThresholdFlag > 0 && len(tablesToCompare) > *confirmThresholdFlag {
+		question := fmt.Sprintf("You've selected %d tables for comparison. This might take a while. Continue?", len(tablesToCompare))
+		if !confirm.confirm(question, false) {
+			log.Println("Operation cancelled by user")
+			return
+		}
+	}
 
-		result, err := compareTable(devDB, stagingDB, tableName)
-		if err != nil {
-			log.Printf("Error comparing table %s: %v", tableName, err)
-			continue
+	// Ctrl-C or the timeout stops the comparison; the tables compared so far are still reported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutFlag)
+		defer cancel()
+	}
+	go func() {
+		<-ctx.Done()
+		// A second Ctrl-C quits immediately
+		stop()
+	}()
+
+	// Save progress after each table so an interrupted run can be resumed
+	var cp *checkpoint
+	var resumed string
+	if *checkpointFlag != "" {
+		devSource := sourceName(devConfig, *devFileFlag, compareOpts.DevSchema)
+		stagingSource := sourceName(stagingConfig, *stagingFileFlag, compareOpts.StagingSchema)
+		settings := resultSettings(describeMode(*modeFlag, *countMethodFlag, *sampleMethodFlag, compareOpts.SampleRate), compareOpts, queries)
+		cp = newCheckpoint(*checkpointFlag, devSource, stagingSource, settings)
+		cp.SampleSalt = compareOpts.SampleSalt
+		if !compareOpts.Masks.reproducible() {
+			// The random key isn't saved, so new hashes wouldn't match the saved ones
+			if *resumeFlag {
+				log.Fatalf("-resume with hash masks needs MASK_KEY set to the key of the interrupted run (a run with a random key can't be resumed)")
+			}
+			log.Printf("Warning: hash masks use a random key; set MASK_KEY to be able to -resume from %s", *checkpointFlag)
+		}
+		if *resumeFlag {
+			loaded, err := loadCheckpoint(*checkpointFlag, devSource, stagingSource, settings)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				log.Printf("No checkpoint %s found, comparing all tables", *checkpointFlag)
+			case err != nil:
+				log.Fatalf("Failed to resume: %v", err)
+			default:
+				cp = loaded
+				compareOpts.SampleSalt = cp.SampleSalt
+				resumed = fmt.Sprintf("%d tables from checkpoint of %s", len(cp.Results), cp.CreatedAt.Format("2006-01-02 15:04:05"))
+				log.Printf("Resuming from checkpoint %s: %s", *checkpointFlag, resumed)
+			}
 		}
+	} else if *resumeFlag {
+		log.Fatalf("-resume needs a -checkpoint file")
+	}
 
-		// Get actual differences to show in log
-		differences := result["differences"].([]map[string]interface{})
-		onlyInDev := result["only_in_dev"].([]map[string]interface{})
-		onlyInStaging := result["only_in_staging"].([]map[string]interface{})
+	// Compare selected tables
+	results, notCompared, failed, err := compareTables(ctx, devReader, stagingReader, tablesToCompare, compare, *workersFlag, cp)
+	if err != nil {
+		log.Fatalf("Failed to compare tables: %v", err)
+	}
 
-		log.Printf("Table %s: %d value differences, %d records only in dev, %d records only in staging",
-			tableName, len(differences), len(onlyInDev), len(onlyInStaging))
+	var interrupted string
+	if len(notCompared) > 0 {
+		interrupted = "interrupted"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			interrupted = fmt.Sprintf("timeout after %s", *timeoutFlag)
+		}
+		log.Printf("Comparison stopped (%s): %d of %d tables not compared, writing a partial report",
+			interrupted, len(notCompared), len(tablesToCompare))
+	}
+	if len(failed) > 0 {
+		log.Printf("%d of %d tables failed to compare, writing an incomplete report", len(failed), len(tablesToCompare))
+	}
 
-		results = append(results, result)
+	// Compare database objects, unless the run was stopped. Kinds that fail
+	// are recorded; the table results are reported either way.
+	var objectDiffs []objectDiff
+	var objectErrors []string
+	if len(objectKinds) > 0 && interrupted == "" {
+		log.Printf("Comparing %s", strings.Join(objectKindNames(objectKinds), ", "))
+		objectDiffs, objectErrors = compareObjects(ctx, devReader, stagingReader, compareOpts.DevSchema, compareOpts.StagingSchema, objectKinds)
+		for _, objectError := range objectErrors {
+			log.Printf("Error comparing objects: %s", objectError)
+		}
+		log.Printf("Found %d object differences", len(objectDiffs))
 	}
 
 	// Generate filename with timestamp
@@ -905,17 +1390,257 @@ func main() {
 	} else {
 		timestamp := time.Now().Format("20060102_150405")
 		filename = fmt.Sprintf("data_comparison_%s.xlsx", timestamp)
+		if interrupted != "" || len(failed) > 0 {
+			filename = fmt.Sprintf("data_comparison_%s_partial.xlsx", timestamp)
+		}
 	}
 
 	// Export results to Excel
 	log.Printf("Exporting comparison results to %s", filename)
-	if err := exportToExcel(results, filename); err != nil {
+	exportOpts := ExportOptions{
+		DiffView:    *diffViewFlag,
+		ObjectKinds: objectKindNames(objectKinds),
+		Objects:     objectDiffs,
+		RunInfo: &RunInfo{
+			ToolVersion:   toolVersion(),
+			StartedAt:     startedAt,
+			FinishedAt:    time.Now(),
+			DevConfig:     devConfig,
+			StagingConfig: stagingConfig,
+			DevFile:       *devFileFlag,
+			StagingFile:   *stagingFileFlag,
+			Consistent:    *consistentFlag,
+			Interrupted:   interrupted,
+			Resumed:       resumed,
+			NotCompared:   notCompared,
+			Failed:        failed,
+			Queries:       queries,
+			Objects:       objectKindNames(objectKinds),
+			ObjectErrors:  objectErrors,
+			Mode:          describeMode(*modeFlag, *countMethodFlag, *sampleMethodFlag, compareOpts.SampleRate),
+			Mappings:      compareOpts.Mappings.describe(),
+			DevSchema:     compareOpts.DevSchema,
+			StagingSchema: compareOpts.StagingSchema,
+			SameDatabase:  *sameDatabaseFlag,
+			SoftDelete:    compareOpts.SoftDelete,
+			Masks:         compareOpts.Masks.describe(),
+			Flags:         collectFlags(),
+		},
+	}
+	if err := exportToExcel(results, filename, exportOpts); err != nil {
 		log.Fatalf("Failed to export to Excel: %v", err)
 	}
 
+	if interrupted != "" {
+		log.Printf("Comparison incomplete (%s). Partial results saved to %s", interrupted, filename)
+		if cp != nil {
+			log.Printf("Run again with -resume to compare the remaining tables")
+		}
+		os.Exit(1)
+	}
+	if len(failed) > 0 {
+		log.Printf("Comparison incomplete: %d tables failed. Partial results saved to %s", len(failed), filename)
+		if cp != nil {
+			log.Printf("Run again with -resume to retry the failed tables")
+		}
+		os.Exit(1)
+	}
+
+	// The run is complete, so there is nothing left to resume
+	if err := cp.remove(); err != nil {
+		log.Printf("Warning: failed to remove checkpoint %s: %v", *checkpointFlag, err)
+	}
+
 	log.Printf("Comparison completed successfully. Results saved to %s", filename)
 }
 
+// Helper function to check whether two values are the same number. Values
+// that aren't numbers are never equal here.
+func numericEqual(a, b interface{}) bool {
+	ra, okA := ratValue(a)
+	rb, okB := ratValue(b)
+	return okA && okB && ra.Cmp(rb) == 0
+}
+
+// Helper function to get the exact value of a number given as a Go number or
+// as decimal text
+func ratValue(val interface{}) (*big.Rat, bool) {
+	if val == nil {
+		return nil, false
+	}
+	text := fmt.Sprintf("%v", val)
+	if b, ok := val.([]byte); ok {
+		text = string(b)
+	}
+	return new(big.Rat).SetString(strings.TrimSpace(text))
+}
+
+// Function comparing one table (or query) on connections of both sides
+type compareFunc func(ctx context.Context, devDB, stagingDB *gorm.DB, name string) (map[string]interface{}, error)
+
+// Function to compare tables with a number of parallel workers. Results keep
+// the order of the table list; tables that fail to compare are returned as
+// failed, with their error. When the context is cancelled, no new table is
+// started and the tables that were not (fully) compared are returned as
+// notCompared.
+func compareTables(ctx context.Context, devReader, stagingReader *snapshotReader, tables []string, compare compareFunc, workers int, cp *checkpoint) ([]map[string]interface{}, []string, []tableFailure, error) {
+	if workers > len(tables) {
+		workers = len(tables)
+	}
+
+	compared := make([]map[string]interface{}, len(tables))
+	finished := make([]bool, len(tables))
+	errs := make([]error, len(tables))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for id := 0; id < workers; id++ {
+		// Every worker needs its own connections, joined to the same snapshots
+		devConn, devDone, err := devReader.worker(id)
+		if err != nil {
+			close(jobs)
+			wg.Wait()
+			return nil, nil, nil, fmt.Errorf("failed to start worker on development database: %w", err)
+		}
+		stagingConn, stagingDone, err := stagingReader.worker(id)
+		if err != nil {
+			devDone()
+			close(jobs)
+			wg.Wait()
+			return nil, nil, nil, fmt.Errorf("failed to start worker on staging database: %w", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer devDone()
+			defer stagingDone()
+
+			for i := range jobs {
+				tableName := tables[i]
+				if result, ok := cp.result(tableName); ok {
+					log.Printf("Table %s: using result from checkpoint", tableName)
+					compared[i] = result
+					finished[i] = true
+					continue
+				}
+				log.Printf("Comparing table: %s", tableName)
+
+				tableStart := time.Now()
+				result, err := compareIsolated(ctx, devReader, stagingReader, devConn, stagingConn, tableName, compare)
+				if err != nil {
+					if ctx.Err() != nil {
+						log.Printf("Comparison of table %s stopped: %v", tableName, ctx.Err())
+						continue
+					}
+					log.Printf("Error comparing table %s: %v", tableName, err)
+					errs[i] = err
+					finished[i] = true
+					continue
+				}
+				result["duration"] = time.Since(tableStart)
+
+				// Get actual differences to show in log
+				differences := result["differences"].([]map[string]interface{})
+				onlyInDev := result["only_in_dev"].([]map[string]interface{})
+				onlyInStaging := result["only_in_staging"].([]map[string]interface{})
+
+				log.Printf("Table %s: %d value differences, %d records only in dev, %d records only in staging",
+					tableName, len(differences), len(onlyInDev), len(onlyInStaging))
+
+				compared[i] = result
+				finished[i] = true
+
+				if err := cp.save(result); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range tables {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var results []map[string]interface{}
+	var notCompared []string
+	var failed []tableFailure
+	for i, result := range compared {
+		if result != nil {
+			results = append(results, result)
+		}
+		if !finished[i] {
+			notCompared = append(notCompared, tables[i])
+		}
+		if errs[i] != nil {
+			failed = append(failed, tableFailure{Table: tables[i], Error: errs[i].Error()})
+		}
+	}
+	return results, notCompared, failed, nil
+}
+
+// A table whose comparison failed, with the error
+type tableFailure struct {
+	Table string
+	Error string
+}
+
+// Function to compare one table inside savepoints on both worker
+// connections, so a failing table doesn't abort the snapshot transaction for
+// the tables after it
+func compareIsolated(ctx context.Context, devReader, stagingReader *snapshotReader, devConn, stagingConn *gorm.DB, tableName string, compare compareFunc) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := withSavepoints(devReader, stagingReader, devConn, stagingConn, func() error {
+		var err error
+		result, err = compare(ctx, devConn, stagingConn, tableName)
+		return err
+	})
+	return result, err
+}
+
+// Function to describe where one side of the comparison is read from
+func sourceName(config DBConfig, file, schema string) string {
+	if file != "" {
+		return "file " + file
+	}
+	if schema != "public" {
+		return fmt.Sprintf("%s schema %s", config.String(), schema)
+	}
+	return config.String()
+}
+
+// Function to read the connection settings of an environment from the
+// <PREFIX>_DB_* environment variables
+func envDBConfig(prefix string) DBConfig {
+	return dbconfig.FromEnv(prefix + "_")
+}
+
+// Helper function to check whether a slice contains a string
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to lower-case every string of a slice
+func lowerStrings(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(v)
+	}
+	return lowered
+}
+
 // Helper function to get environment variable with fallback
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDiffRows(t *testing.T) {
+	columns := []string{"id", "name"}
+	types := map[string]string{"id": "integer", "name": "text"}
+	dev := []map[string]interface{}{
+		{"id": int64(1), "name": "same"},
+		{"id": int64(2), "name": "dev"},
+		{"id": int64(3), "name": "only dev"},
+	}
+	staging := []map[string]interface{}{
+		{"id": int64(1), "name": "same"},
+		{"id": int64(2), "name": "staging"},
+		{"id": int64(4), "name": "only staging"},
+	}
+
+	result := diffRows("items", columns, types, []string{"id"}, dev, staging, 3, 3, CompareOptions{})
+
+	differences := result["differences"].([]map[string]interface{})
+	if len(differences) != 1 {
+		t.Fatalf("differences = %v, want one", differences)
+	}
+	d := differences[0]
+	if d["key"] != "id:2" || d["pk_id"] != int64(2) || d["column"] != "name" || d["dev_value"] != "dev" || d["staging_value"] != "staging" {
+		t.Errorf("difference = %v, want key id:2, column name, dev and staging values", d)
+	}
+
+	tests := []struct {
+		field string
+		want  []string
+	}{
+		{"only_in_dev", []string{"only dev"}},
+		{"only_in_staging", []string{"only staging"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, row := range result[tt.field].([]map[string]interface{}) {
+			names = append(names, row["name"].(string))
+		}
+		sort.Strings(names)
+		if len(names) != len(tt.want) || names[0] != tt.want[0] {
+			t.Errorf("%s = %v, want %v", tt.field, names, tt.want)
+		}
+	}
+
+	if result["matched_rows"] != 2 {
+		t.Errorf("matched_rows = %v, want 2", result["matched_rows"])
+	}
+}
+
+func TestDiffRowsCompositeKey(t *testing.T) {
+	columns := []string{"role", "permission", "granted"}
+	types := map[string]string{"role": "text", "permission": "text", "granted": "boolean"}
+	keys := []string{"role", "permission"}
+	dev := []map[string]interface{}{{"role": "admin", "permission": "read", "granted": true}}
+	staging := []map[string]interface{}{{"role": "admin", "permission": "read", "granted": false}}
+
+	result := diffRows("grants", columns, types, keys, dev, staging, 1, 1, CompareOptions{})
+	differences := result["differences"].([]map[string]interface{})
+	if len(differences) != 1 {
+		t.Fatalf("differences = %v, want one", differences)
+	}
+	d := differences[0]
+	if d["key"] != "role:admin|permission:read" || d["pk_role"] != "admin" || d["pk_permission"] != "read" {
+		t.Errorf("difference key = %v (pk_role %v, pk_permission %v), want role:admin|permission:read", d["key"], d["pk_role"], d["pk_permission"])
+	}
+}
+
+func TestDiffRowsSoftDelete(t *testing.T) {
+	columns := []string{"id", "name", "is_deleted"}
+	types := map[string]string{"id": "integer", "name": "text", "is_deleted": "boolean"}
+	report := &SoftDeleteConfig{Mode: softDeleteReport, FlagColumns: []string{"is_deleted"}}
+	dev := []map[string]interface{}{
+		{"id": int64(1), "name": "a", "is_deleted": false},
+		{"id": int64(2), "name": "b", "is_deleted": true},
+		{"id": int64(3), "name": "c", "is_deleted": true},
+		{"id": int64(4), "name": "d", "is_deleted": false},
+	}
+	staging := []map[string]interface{}{
+		{"id": int64(1), "name": "a", "is_deleted": true},   // deleted in staging only
+		{"id": int64(2), "name": "b", "is_deleted": false},  // deleted in dev only
+		{"id": int64(3), "name": "c2", "is_deleted": false}, // deleted in dev, and renamed
+		{"id": int64(4), "name": "d2", "is_deleted": false}, // value difference
+	}
+
+	tests := []struct {
+		name            string
+		softDelete      *SoftDeleteConfig
+		wantDifferences []string // key/column of each value difference
+		wantDeletedDev  int
+		wantDeletedStg  int
+	}{
+		{"off", nil, []string{"id:1/is_deleted", "id:2/is_deleted", "id:3/is_deleted", "id:3/name", "id:4/name"}, 0, 0},
+		{"report", report, []string{"id:3/name", "id:4/name"}, 2, 1},
+	}
+
+	for _, tt := range tests {
+		result := diffRows("items", columns, types, []string{"id"}, dev, staging, 4, 4, CompareOptions{SoftDelete: tt.softDelete})
+
+		var got []string
+		for _, d := range result["differences"].([]map[string]interface{}) {
+			got = append(got, d["key"].(string)+"/"+d["column"].(string))
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.wantDifferences, ",") {
+			t.Errorf("%s: differences = %v, want %v", tt.name, got, tt.wantDifferences)
+		}
+
+		if tt.softDelete == nil {
+			if _, ok := result["deleted_in_dev"]; ok {
+				t.Errorf("%s: result has deleted_in_dev, want none without -soft-delete=report", tt.name)
+			}
+			continue
+		}
+		if n := len(result["deleted_in_dev"].([]map[string]interface{})); n != tt.wantDeletedDev {
+			t.Errorf("%s: deleted_in_dev has %d rows, want %d", tt.name, n, tt.wantDeletedDev)
+		}
+		if n := len(result["deleted_in_staging"].([]map[string]interface{})); n != tt.wantDeletedStg {
+			t.Errorf("%s: deleted_in_staging has %d rows, want %d", tt.name, n, tt.wantDeletedStg)
+		}
+
+		// The changed record of a deleted row lists only its other columns
+		for _, record := range result["changed_records"].([]map[string]interface{}) {
+			for _, col := range record["changed_columns"].([]string) {
+				if col == "is_deleted" {
+					t.Errorf("%s: changed record %v lists the soft-delete column", tt.name, record["key"])
+				}
+			}
+		}
+	}
+}
+
+func TestDiffRowsMaskedKey(t *testing.T) {
+	masks, err := parseMasks(map[string]string{"email": "hash"}, "test key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := []string{"email", "name"}
+	types := map[string]string{"email": "text", "name": "text"}
+	dev := []map[string]interface{}{{"email": "a@example.com", "name": "Ann"}}
+	staging := []map[string]interface{}{{"email": "a@example.com", "name": "Anne"}}
+
+	result := diffRows("users", columns, types, []string{"email"}, dev, staging, 1, 1, CompareOptions{Masks: masks})
+
+	differences := result["differences"].([]map[string]interface{})
+	if len(differences) != 1 {
+		t.Fatalf("differences = %v, want one (rows still match on the real key)", differences)
+	}
+	hash := masks.maskValue("users", "email", "a@example.com")
+	d := differences[0]
+	if d["key"] != "email:"+hash.(string) || d["pk_email"] != hash {
+		t.Errorf("difference key = %v, pk_email = %v, want the masked key %v", d["key"], d["pk_email"], hash)
+	}
+	for _, field := range []string{"key", "pk_email", "dev_value", "staging_value"} {
+		if strings.Contains(fmt.Sprintf("%v", d[field]), "example.com") {
+			t.Errorf("difference %s = %v shows the unmasked e-mail", field, d[field])
+		}
+	}
+}
+
+func TestSelectRowsSQL(t *testing.T) {
+	sample := sampleCondition([]string{"id"}, 0.01, "", func(col string) string { return col })
+
+	tests := []struct {
+		name      string
+		condition string
+		limit     bool
+		want      string
+	}{
+		{"full table", "", true, "SELECT id, name FROM public.items LIMIT 1000"},
+		{"live rows", "deleted_at IS NULL", true, "SELECT id, name FROM public.items WHERE deleted_at IS NULL LIMIT 1000"},
+		// A sample is read whole: an unordered LIMIT could keep different
+		// rows on each side and report them as only in one environment
+		{"sample", sample, false, "SELECT id, name FROM public.items WHERE " + sample},
+	}
+
+	for _, tt := range tests {
+		if got := selectRowsSQL("id, name", "public.items", tt.condition, tt.limit); got != tt.want {
+			t.Errorf("%s: selectRowsSQL = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResultSettingsIncludeMode(t *testing.T) {
+	modes := []string{
+		describeMode("table", "exact", "hash", 0),
+		describeMode("count", "exact", "hash", 0),
+		describeMode("count", "estimate", "hash", 0),
+		describeMode("sample", "exact", "hash", 0.01),
+		describeMode("sample", "exact", "hash", 0.05),
+		describeMode("sample", "exact", "random", 0.01),
+	}
+
+	seen := make(map[string]string)
+	for _, mode := range modes {
+		settings := resultSettings(mode, CompareOptions{}, nil)
+		if other, ok := seen[settings]; ok {
+			t.Errorf("modes %q and %q have the same settings %q", other, mode, settings)
+		}
+		seen[settings] = mode
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Table and column renames between the environments from the config file,
+// so a comparison still lines up while a rename migration has only reached
+// one of them. Names are given as dev name: staging name, for example:
+//
+//	mappings:
+//	  tables:
+//	    role_permissions: role_permission
+//	  columns:
+//	    role_permissions:
+//	      permission_code: permission
+type MappingConfig struct {
+	Tables  map[string]string            `yaml:"tables"`  // dev table -> staging table
+	Columns map[string]map[string]string `yaml:"columns"` // dev table -> dev column -> staging column
+}
+
+// Function to check that no two dev names map to the same staging name
+func (m *MappingConfig) validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := checkUniqueMapping(m.Tables, "table"); err != nil {
+		return err
+	}
+	for table, columns := range m.Columns {
+		if err := checkUniqueMapping(columns, "column of table "+table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkUniqueMapping(mapping map[string]string, what string) error {
+	seen := make(map[string]string)
+	for devName, stagingName := range mapping {
+		if other, ok := seen[stagingName]; ok {
+			return fmt.Errorf("%s %s and %s both map to %s", what, other, devName, stagingName)
+		}
+		seen[stagingName] = devName
+	}
+	return nil
+}
+
+// Function to get the staging name of a dev table
+func (m *MappingConfig) stagingTable(devTable string) string {
+	if m == nil {
+		return devTable
+	}
+	if name, ok := m.Tables[devTable]; ok {
+		return name
+	}
+	return devTable
+}
+
+// Function to get the staging name of a column of a dev table
+func (m *MappingConfig) stagingColumn(devTable, devColumn string) string {
+	if m == nil {
+		return devColumn
+	}
+	if name, ok := m.Columns[devTable][devColumn]; ok {
+		return name
+	}
+	return devColumn
+}
+
+// Function to describe the mappings for the Run Info sheet, one line each
+func (m *MappingConfig) describe() []string {
+	if m == nil {
+		return nil
+	}
+
+	var lines []string
+	for devTable, stagingTable := range m.Tables {
+		lines = append(lines, fmt.Sprintf("table %s → %s", devTable, stagingTable))
+	}
+	for devTable, columns := range m.Columns {
+		for devColumn, stagingColumn := range columns {
+			lines = append(lines, fmt.Sprintf("column %s.%s → %s", devTable, devColumn, stagingColumn))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
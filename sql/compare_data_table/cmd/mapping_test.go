@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMappingConfigNames(t *testing.T) {
+	m := &MappingConfig{
+		Tables:  map[string]string{"role_permissions": "role_permission"},
+		Columns: map[string]map[string]string{"role_permissions": {"permission_code": "permission"}},
+	}
+
+	tests := []struct {
+		mapping            *MappingConfig
+		table, column      string
+		wantTable, wantCol string
+	}{
+		{m, "role_permissions", "permission_code", "role_permission", "permission"},
+		{m, "role_permissions", "role_id", "role_permission", "role_id"},
+		{m, "users", "permission_code", "users", "permission_code"},
+		{nil, "role_permissions", "permission_code", "role_permissions", "permission_code"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.mapping.stagingTable(tt.table); got != tt.wantTable {
+			t.Errorf("stagingTable(%q) = %q, want %q", tt.table, got, tt.wantTable)
+		}
+		if got := tt.mapping.stagingColumn(tt.table, tt.column); got != tt.wantCol {
+			t.Errorf("stagingColumn(%q, %q) = %q, want %q", tt.table, tt.column, got, tt.wantCol)
+		}
+	}
+}
+
+func TestMappingConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping *MappingConfig
+		wantErr string
+	}{
+		{"nil", nil, ""},
+		{"unique", &MappingConfig{Tables: map[string]string{"a": "x", "b": "y"}}, ""},
+		{"tables clash", &MappingConfig{Tables: map[string]string{"a": "x", "b": "x"}}, "both map to x"},
+		{"columns clash", &MappingConfig{Columns: map[string]map[string]string{"t": {"a": "c", "b": "c"}}}, "column of table t"},
+	}
+
+	for _, tt := range tests {
+		err := tt.mapping.validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: validate() = %v, want no error", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: validate() = %v, want an error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMappingConfigDescribe(t *testing.T) {
+	m := &MappingConfig{
+		Tables:  map[string]string{"b": "b2", "a": "a2"},
+		Columns: map[string]map[string]string{"a": {"c": "c2"}},
+	}
+	want := []string{"column a.c → c2", "table a → a2", "table b → b2"}
+	if got := m.describe(); !reflect.DeepEqual(got, want) {
+		t.Errorf("describe() = %q, want %q", got, want)
+	}
+
+	// Mappings change which rows line up, so a checkpoint must not be resumed across them
+	if resultSettings("", CompareOptions{Mappings: m}, nil) == resultSettings("", CompareOptions{}, nil) {
+		t.Error("resultSettings is the same with and without mappings")
+	}
+}
+
+func TestStagingRef(t *testing.T) {
+	mappings := &MappingConfig{Tables: map[string]string{
+		"users":  "users_v2",
+		"orders": "archive.orders",
+	}}
+
+	tests := []struct {
+		opts                  CompareOptions
+		table                 string
+		wantSchema, wantTable string
+	}{
+		{CompareOptions{StagingSchema: "public"}, "users", "public", "users"},
+		{CompareOptions{StagingSchema: "copy"}, "users", "copy", "users"},
+		{CompareOptions{StagingSchema: "copy", Mappings: mappings}, "users", "copy", "users_v2"},
+		{CompareOptions{StagingSchema: "copy", Mappings: mappings}, "orders", "archive", "orders"},
+		{CompareOptions{StagingSchema: "public", Mappings: mappings}, "items", "public", "items"},
+	}
+
+	for _, tt := range tests {
+		schema, table := tt.opts.stagingRef(tt.table)
+		if schema != tt.wantSchema || table != tt.wantTable {
+			t.Errorf("stagingRef(%q) with schema %s = %s.%s, want %s.%s", tt.table, tt.opts.StagingSchema, schema, table, tt.wantSchema, tt.wantTable)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Value shown instead of a redacted column value
+const redactedValue = "***"
+
+// A masking method bound to a table/column pattern
+type maskRule struct {
+	pattern string
+	method  string
+}
+
+// Collection of masking rules for sensitive columns. Masked values replace
+// the originals in every output (report, checkpoint, logs); differences are
+// still detected on the original values.
+type MaskSet struct {
+	rules    []maskRule
+	key      []byte // HMAC key for "hash"
+	fixedKey bool   // whether the key was given (MASK_KEY) rather than random per run
+
+	mu    sync.Mutex
+	cache map[string]string // table.column -> method ("" when not masked)
+}
+
+// Function to build a mask set from "pattern: method" entries. The hash key
+// is the given key, or random for this run when it is empty. The key is never
+// written to an output.
+//
+// Patterns are "column" or "table.column" globs, as for normalizers; the most
+// specific matching pattern decides. Supported methods:
+//
+//	hash    replace the value by a keyed hash, equal values get equal hashes
+//	redact  replace the value by ***
+//	none    don't mask (to exempt a column from a broader pattern)
+func parseMasks(spec map[string]string, key string) (*MaskSet, error) {
+	if len(spec) == 0 {
+		return nil, nil
+	}
+
+	set := &MaskSet{cache: make(map[string]string)}
+	for pattern, method := range spec {
+		pattern = strings.TrimSpace(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid mask pattern %q: %w", pattern, err)
+		}
+
+		method = strings.ToLower(strings.TrimSpace(method))
+		switch method {
+		case "hash", "redact", "none":
+		default:
+			return nil, fmt.Errorf("unknown mask method %q for %q: expected hash, redact or none", method, pattern)
+		}
+		set.rules = append(set.rules, maskRule{pattern: pattern, method: method})
+	}
+
+	// Generic rules first, so the most specific match is the last one
+	sort.SliceStable(set.rules, func(i, j int) bool {
+		si, sj := patternSpecificity(set.rules[i].pattern), patternSpecificity(set.rules[j].pattern)
+		if si != sj {
+			return si < sj
+		}
+		return set.rules[i].pattern < set.rules[j].pattern
+	})
+
+	if key != "" {
+		set.key, set.fixedKey = []byte(key), true
+		return set, nil
+	}
+	set.key = make([]byte, 32)
+	if _, err := rand.Read(set.key); err != nil {
+		return nil, fmt.Errorf("failed to create mask key: %w", err)
+	}
+
+	return set, nil
+}
+
+// Function to get the masking method of a table column ("" when not masked)
+func (s *MaskSet) forColumn(tableName, column string) string {
+	if s == nil {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cacheKey := tableName + "." + column
+	if method, ok := s.cache[cacheKey]; ok {
+		return method
+	}
+
+	method := ""
+	for _, rule := range s.rules {
+		if matchColumnPattern(rule.pattern, tableName, column) {
+			method = rule.method
+		}
+	}
+	if method == "none" {
+		method = ""
+	}
+
+	s.cache[cacheKey] = method
+	return method
+}
+
+// Function to check whether any rule hashes values
+func (s *MaskSet) hashes() bool {
+	if s == nil {
+		return false
+	}
+	for _, rule := range s.rules {
+		if rule.method == "hash" {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to check whether hashes can be reproduced by a later run: with a
+// random key they only match within one run
+func (s *MaskSet) reproducible() bool {
+	return !s.hashes() || s.fixedKey
+}
+
+// Function to get a short check value of the hash key, so a checkpoint can
+// tell whether it was written with the same key without storing the key
+func (s *MaskSet) keyCheck() string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("compare_data_table mask key check"))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// Function to check whether a table column is masked
+func (s *MaskSet) masks(tableName, column string) bool {
+	return s.forColumn(tableName, column) != ""
+}
+
+// Function to mask a single value. NULL stays NULL.
+func (s *MaskSet) maskValue(tableName, column string, val interface{}) interface{} {
+	method := s.forColumn(tableName, column)
+	if method == "" || val == nil {
+		return val
+	}
+
+	if method == "redact" {
+		return redactedValue
+	}
+
+	text := fmt.Sprintf("%v", val)
+	if b, ok := val.([]byte); ok {
+		text = string(b)
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(text))
+	return "hash:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// Function to return a masked copy of a row for the given table
+func (s *MaskSet) maskRow(tableName string, row map[string]interface{}) map[string]interface{} {
+	if s == nil {
+		return row
+	}
+
+	masked := make(map[string]interface{}, len(row))
+	for col, val := range row {
+		masked[col] = s.maskValue(tableName, col, val)
+	}
+	return masked
+}
+
+// Function to return masked copies of rows for the given table
+func (s *MaskSet) maskRows(tableName string, rows []map[string]interface{}) []map[string]interface{} {
+	if s == nil {
+		return rows
+	}
+
+	masked := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		masked[i] = s.maskRow(tableName, row)
+	}
+	return masked
+}
+
+// Function to describe the masking rules for the Run Info sheet
+func (s *MaskSet) describe() []string {
+	if s == nil {
+		return nil
+	}
+
+	lines := make([]string, len(s.rules))
+	for i, rule := range s.rules {
+		lines[i] = fmt.Sprintf("%s: %s", rule.pattern, rule.method)
+	}
+	return lines
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskSetForColumn(t *testing.T) {
+	masks, err := parseMasks(map[string]string{
+		"*email*":              "hash",
+		"users.email_verified": "none",
+		"password*":            "redact",
+		"users.password_hint":  "hash",
+		"audit.*":              "redact",
+	}, "test key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		table, column string
+		want          string
+	}{
+		{"users", "email", "hash"},
+		{"orders", "customer_email", "hash"},
+		{"users", "email_verified", ""}, // exempted by the more specific rule
+		{"admins", "email_verified", "hash"},
+		{"users", "password", "redact"},
+		{"users", "password_hint", "hash"}, // table.column beats column pattern
+		{"audit", "email", "redact"},       // table.* beats a column pattern
+		{"users", "name", ""},
+	}
+
+	for _, tt := range tests {
+		if got := masks.forColumn(tt.table, tt.column); got != tt.want {
+			t.Errorf("forColumn(%s, %s) = %q, want %q", tt.table, tt.column, got, tt.want)
+		}
+	}
+
+	var none *MaskSet
+	if got := none.forColumn("users", "email"); got != "" {
+		t.Errorf("forColumn without masks = %q, want none", got)
+	}
+}
+
+func TestMaskSetMaskValue(t *testing.T) {
+	spec := map[string]string{"email": "hash", "password": "redact"}
+	masks, err := parseMasks(spec, "test key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := masks.maskValue("users", "email", "a@example.com")
+	tests := []struct {
+		column string
+		val    interface{}
+		want   interface{}
+	}{
+		{"email", "a@example.com", hash},
+		{"email", []byte("a@example.com"), hash}, // bytes hash like their text
+		{"email", nil, nil},
+		{"password", "secret", redactedValue},
+		{"password", nil, nil},
+		{"name", "Ann", "Ann"},
+	}
+
+	for _, tt := range tests {
+		if got := masks.maskValue("users", tt.column, tt.val); got != tt.want {
+			t.Errorf("maskValue(%s, %v) = %v, want %v", tt.column, tt.val, got, tt.want)
+		}
+	}
+
+	if s, _ := hash.(string); !strings.HasPrefix(s, "hash:") || len(s) != len("hash:")+12 || strings.Contains(s, "example") {
+		t.Errorf("hash = %v, want hash: and 12 hex digits", hash)
+	}
+	if other := masks.maskValue("users", "email", "b@example.com"); other == hash {
+		t.Errorf("different values hash to the same %v", hash)
+	}
+
+	// The same key gives the same hashes in another run; another key doesn't
+	same, _ := parseMasks(spec, "test key")
+	other, _ := parseMasks(spec, "other key")
+	if got := same.maskValue("users", "email", "a@example.com"); got != hash {
+		t.Errorf("hash with the same key = %v, want %v", got, hash)
+	}
+	if got := other.maskValue("users", "email", "a@example.com"); got == hash {
+		t.Errorf("hash with another key = %v, want a different hash", got)
+	}
+}
+
+func TestMaskSetKey(t *testing.T) {
+	spec := map[string]string{"email": "hash"}
+	fixed, _ := parseMasks(spec, "test key")
+	random, _ := parseMasks(spec, "")
+	random2, _ := parseMasks(spec, "")
+	redactOnly, _ := parseMasks(map[string]string{"email": "redact"}, "")
+
+	tests := []struct {
+		name  string
+		masks *MaskSet
+		want  bool
+	}{
+		{"fixed key", fixed, true},
+		{"random key", random, false},
+		{"redact only", redactOnly, true},
+		{"no masks", nil, true},
+	}
+	for _, tt := range tests {
+		if got := tt.masks.reproducible(); got != tt.want {
+			t.Errorf("%s: reproducible() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	if random.keyCheck() == random2.keyCheck() {
+		t.Error("two random keys have the same check value")
+	}
+
+	// The settings hold a check value of the key, never the key itself
+	settings := resultSettings("", CompareOptions{Masks: fixed}, nil)
+	if strings.Contains(settings, "test key") || !strings.Contains(settings, fixed.keyCheck()) {
+		t.Errorf("resultSettings = %q, want the key check and not the key", settings)
+	}
+}
+
+func TestParseMasksErrors(t *testing.T) {
+	for _, spec := range []map[string]string{
+		{"email": "scramble"},
+		{"[email": "hash"},
+	} {
+		if _, err := parseMasks(spec, ""); err == nil {
+			t.Errorf("parseMasks(%v) succeeded, want an error", spec)
+		}
+	}
+	if masks, err := parseMasks(nil, ""); masks != nil || err != nil {
+		t.Errorf("parseMasks(nil) = %v, %v, want no masks", masks, err)
+	}
+}
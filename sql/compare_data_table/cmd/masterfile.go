@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Master data maintained in a spreadsheet, used as one side of the comparison
+// instead of a database. Either an xlsx workbook with one sheet per table, a
+// single CSV file for one table, or a directory with one <table>.csv per table.
+// The first row of every sheet/file holds the column names.
+type masterDataFile struct {
+	path   string
+	tables map[string][][]string // lower-case table name -> raw rows including header
+	names  map[string]string     // lower-case table name -> name as found in the file
+}
+
+// Function to load a master-data file or directory
+func openMasterDataFile(path string) (*masterDataFile, error) {
+	m := &masterDataFile{
+		path:   path,
+		tables: make(map[string][][]string),
+		names:  make(map[string]string),
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open master-data file %s: %w", path, err)
+	}
+
+	switch {
+	case info.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master-data directory %s: %w", path, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".csv") {
+				continue
+			}
+			if err := m.loadCSV(filepath.Join(path, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+	case strings.EqualFold(filepath.Ext(path), ".csv"):
+		if err := m.loadCSV(path); err != nil {
+			return nil, err
+		}
+	case strings.EqualFold(filepath.Ext(path), ".xlsx"):
+		if err := m.loadWorkbook(path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported master-data file %s: expected .xlsx, .csv or a directory of .csv files", path)
+	}
+
+	if len(m.tables) == 0 {
+		return nil, fmt.Errorf("no tables found in master-data file %s", path)
+	}
+
+	return m, nil
+}
+
+// Function to load one CSV file as a table named after the file
+func (m *masterDataFile) loadCSV(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open CSV file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV file %s: %w", path, err)
+	}
+
+	// Drop a UTF-8 byte order mark written by Excel
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+
+	tableName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	m.addTable(tableName, rows)
+	return nil
+}
+
+// Function to load every sheet of a workbook as a table named after the sheet
+func (m *masterDataFile) loadWorkbook(path string) error {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open workbook %s: %w", path, err)
+	}
+	defer f.Close()
+
+	for _, sheet := range f.GetSheetList() {
+		// Raw values, so dates come back as serial numbers instead of display text
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return fmt.Errorf("failed to read sheet %s of %s: %w", sheet, path, err)
+		}
+		m.addTable(sheet, rows)
+	}
+
+	return nil
+}
+
+// Helper function to register a table's raw rows
+func (m *masterDataFile) addTable(tableName string, rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	key := strings.ToLower(strings.TrimSpace(tableName))
+	m.tables[key] = rows
+	m.names[key] = tableName
+}
+
+// Function to list the tables available in the file
+func (m *masterDataFile) tableNames() []string {
+	var names []string
+	for key := range m.tables {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Function to check whether the file has data for a table
+func (m *masterDataFile) hasTable(tableName string) bool {
+	_, ok := m.tables[strings.ToLower(tableName)]
+	return ok
+}
+
+// Function to get the columns of a table that are present in the file,
+// in the order of the given database columns
+func (m *masterDataFile) columnsFor(tableName string, columns []string) []string {
+	rows, ok := m.tables[strings.ToLower(tableName)]
+	if !ok {
+		return nil
+	}
+
+	present := make(map[string]bool)
+	for _, header := range rows[0] {
+		present[strings.ToLower(strings.TrimSpace(header))] = true
+	}
+
+	var found []string
+	for _, col := range columns {
+		if present[strings.ToLower(col)] {
+			found = append(found, col)
+		}
+	}
+	return found
+}
+
+// Function to read a table from the file as database-like rows. Cell text is
+// coerced to Go values based on the database column types, so the values
+// compare equal to what the database driver returns.
+func (m *masterDataFile) readTable(tableName string, columns []string, columnTypes map[string]string) ([]map[string]interface{}, error) {
+	rows, ok := m.tables[strings.ToLower(tableName)]
+	if !ok {
+		return nil, fmt.Errorf("table %s not found in master-data file %s", tableName, m.path)
+	}
+
+	// Map header positions to database column names
+	positions := make(map[string]int)
+	for i, header := range rows[0] {
+		positions[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+
+	var data []map[string]interface{}
+	for rowIdx, raw := range rows[1:] {
+		if isBlankRow(raw) {
+			continue
+		}
+
+		row := make(map[string]interface{})
+		for _, col := range columns {
+			pos, ok := positions[strings.ToLower(col)]
+			if !ok {
+				continue
+			}
+
+			var text string
+			if pos < len(raw) {
+				text = raw[pos]
+			}
+
+			val, err := coerceFileValue(text, columnTypes[col])
+			if err != nil {
+				// Header is row 1, so data row N is line N+2
+				return nil, fmt.Errorf("%s row %d, column %s: %w", m.names[strings.ToLower(tableName)], rowIdx+2, col, err)
+			}
+			row[col] = val
+		}
+		data = append(data, row)
+	}
+
+	return data, nil
+}
+
+// Helper function to check whether all cells of a row are empty
+func isBlankRow(raw []string) bool {
+	for _, cell := range raw {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Function to convert spreadsheet text into the Go type the database driver
+// returns for the column's data type. Empty cells are NULL, except in text
+// columns where they are the empty string.
+func coerceFileValue(text, dataType string) (interface{}, error) {
+	if text == "" {
+		if isTextType(dataType) {
+			return "", nil
+		}
+		return nil, nil
+	}
+	trimmed := strings.TrimSpace(text)
+
+	switch strings.ToLower(dataType) {
+	case "smallint", "integer", "bigint":
+		if i, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return i, nil
+		}
+		// Spreadsheets often store whole numbers as "12.0"
+		f, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil || f != float64(int64(f)) {
+			return nil, fmt.Errorf("invalid integer %q", text)
+		}
+		return int64(f), nil
+
+	case "numeric", "decimal":
+		// Kept as decimal text so no precision is lost; numerics are compared
+		// by value, so 12.50 matches 12.5
+		if _, ok := new(big.Rat).SetString(trimmed); !ok || strings.Contains(trimmed, "/") {
+			return nil, fmt.Errorf("invalid number %q", text)
+		}
+		return trimmed, nil
+
+	case "real":
+		// Rounded to single precision, as the database stores it
+		f, err := strconv.ParseFloat(trimmed, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", text)
+		}
+		return float32(f), nil
+
+	case "double precision":
+		f, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", text)
+		}
+		return f, nil
+
+	case "boolean":
+		switch strings.ToLower(trimmed) {
+		case "true", "t", "yes", "y", "1":
+			return true, nil
+		case "false", "f", "no", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid boolean %q", text)
+
+	case "date", "timestamp without time zone", "timestamp with time zone":
+		t, err := parseFileTime(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	return text, nil
+}
+
+// Excel serial of the day after 9999-12-31, the last date Excel can hold
+const maxExcelSerial = 2958466
+
+// Helper function to check whether a PostgreSQL data type holds text
+func isTextType(dataType string) bool {
+	switch strings.ToLower(dataType) {
+	case "text", "character varying", "character", "citext", "name":
+		return true
+	}
+	return false
+}
+
+// Helper function to parse a date/time cell: either an Excel serial date or text
+func parseFileTime(text string) (time.Time, error) {
+	// Serials past 9999-12-31 aren't Excel dates; 20240101 is read as YYYYMMDD
+	if serial, err := strconv.ParseFloat(text, 64); err == nil && serial >= 0 && serial < maxExcelSerial {
+		return excelize.ExcelDateToTime(serial, false)
+	}
+
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04",
+		"2006-01-02",
+		"20060102",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date/time %q", text)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoerceFileValue(t *testing.T) {
+	tests := []struct {
+		text, dataType string
+		want           interface{}
+		wantErr        bool
+	}{
+		{"", "integer", nil, false},
+		{"", "date", nil, false},
+		{"", "text", "", false},
+		{"", "character varying", "", false},
+		{" 42 ", "bigint", int64(42), false},
+		{"12.0", "integer", int64(12), false},
+		{"12.5", "integer", nil, true},
+		{"12345678901234567890.123456789", "numeric", "12345678901234567890.123456789", false},
+		{" 12.50 ", "decimal", "12.50", false},
+		{"1/3", "numeric", nil, true},
+		{"abc", "numeric", nil, true},
+		{"0.1", "real", float32(0.1), false},
+		{"0.1", "double precision", 0.1, false},
+		{"Yes", "boolean", true, false},
+		{"0", "boolean", false, false},
+		{"maybe", "boolean", nil, true},
+		{"2024-03-05", "date", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), false},
+		{"45356", "date", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), false},
+		{"20240305", "date", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), false},
+		{"99999999", "date", nil, true},
+		{"2024-03-05 10:30", "timestamp without time zone", time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC), false},
+		{" text ", "text", " text ", false},
+	}
+
+	for _, tt := range tests {
+		got, err := coerceFileValue(tt.text, tt.dataType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("coerceFileValue(%q, %q) error = %v, want error %t", tt.text, tt.dataType, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerceFileValue(%q, %q) = %#v, want %#v", tt.text, tt.dataType, got, tt.want)
+		}
+	}
+}
+
+func TestNumericEqual(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want bool
+	}{
+		// Database numerics scan as float64, file numerics stay decimal text
+		{12.5, "12.50", true},
+		{0.1, "0.1", true},
+		{int64(3), "3.000", true},
+		{[]byte("1.10"), "1.1", true},
+		{float32(0.5), 0.5, true},
+		{"12345678901234567890.1", "12345678901234567890.2", false},
+		{12.5, "12.51", false},
+		{nil, "0", false},
+		{"abc", "abc", false},
+	}
+
+	for _, tt := range tests {
+		if got := numericEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("numericEqual(%#v, %#v) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Function type that transforms a single column value before comparison
+type normalizer func(val interface{}) interface{}
+
+// A set of normalizers bound to a table/column pattern
+type normalizeRule struct {
+	pattern string
+	names   []string
+	funcs   []normalizer
+}
+
+// Collection of normalization rules applied before values are compared
+type NormalizerSet struct {
+	rules []normalizeRule
+
+	mu    sync.Mutex // parallel workers share the cache
+	cache map[string][]normalizer
+}
+
+// Function to build a normalizer set from "pattern: [normalizer, ...]" entries.
+//
+// Patterns are either "column" (any table) or "table.column", and both parts
+// may use glob wildcards ("*", "?", "[...]"). Supported normalizers:
+//
+//	trim          strip leading and trailing whitespace
+//	lower         case-insensitive comparison
+//	null_empty    treat NULL and '' as equal
+//	line_endings  convert CRLF / CR line endings to LF
+//	round:N       round floats/numerics to N decimal places
+//	truncate:U    truncate timestamps to U (millisecond, second, minute, hour, day)
+func parseNormalizers(spec map[string][]string) (*NormalizerSet, error) {
+	set := &NormalizerSet{cache: make(map[string][]normalizer)}
+
+	for pattern, names := range spec {
+		pattern = strings.TrimSpace(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid normalize pattern %q: %w", pattern, err)
+		}
+
+		rule := normalizeRule{pattern: pattern, names: names}
+		for _, name := range names {
+			fn, err := newNormalizer(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid normalizer for %q: %w", pattern, err)
+			}
+			rule.funcs = append(rule.funcs, fn)
+		}
+		set.rules = append(set.rules, rule)
+	}
+
+	// Apply generic rules first and the most specific ones last, so that for
+	// example "*: [trim]" runs before "users.email: [lower]"
+	sort.SliceStable(set.rules, func(i, j int) bool {
+		si, sj := patternSpecificity(set.rules[i].pattern), patternSpecificity(set.rules[j].pattern)
+		if si != sj {
+			return si < sj
+		}
+		return set.rules[i].pattern < set.rules[j].pattern
+	})
+
+	return set, nil
+}
+
+// Function to describe the normalization rules, one "pattern: names" line each
+func (s *NormalizerSet) describe() []string {
+	if s == nil {
+		return nil
+	}
+
+	lines := make([]string, len(s.rules))
+	for i, rule := range s.rules {
+		lines[i] = fmt.Sprintf("%s: %s", rule.pattern, strings.Join(rule.names, ", "))
+	}
+	return lines
+}
+
+// Helper function to rank patterns from generic to specific
+func patternSpecificity(pattern string) int {
+	score := 0
+	if strings.Contains(pattern, ".") {
+		score += 2
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		score++
+	}
+	return score
+}
+
+// Function to create a normalizer from its name and optional argument
+func newNormalizer(spec string) (normalizer, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	name = strings.ToLower(strings.TrimSpace(name))
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "trim":
+		return mapString(strings.TrimSpace), nil
+	case "lower", "case_insensitive", "ci":
+		return mapString(strings.ToLower), nil
+	case "line_endings", "crlf":
+		return mapString(func(s string) string {
+			s = strings.ReplaceAll(s, "\r\n", "\n")
+			return strings.ReplaceAll(s, "\r", "\n")
+		}), nil
+	case "null_empty", "null_equals_empty":
+		return func(val interface{}) interface{} {
+			if s, ok := stringValue(val); ok && s == "" {
+				return nil
+			}
+			return val
+		}, nil
+	case "round":
+		places, err := strconv.Atoi(arg)
+		if err != nil || places < 0 {
+			return nil, fmt.Errorf("round requires a non-negative number of places, got %q", arg)
+		}
+		return func(val interface{}) interface{} {
+			if f, ok := floatValue(val); ok {
+				return strconv.FormatFloat(f, 'f', places, 64)
+			}
+			return val
+		}, nil
+	case "truncate":
+		unit, err := parseTruncateUnit(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(val interface{}) interface{} {
+			if t, ok := timeValue(val); ok {
+				return truncateTime(t, unit)
+			}
+			return val
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown normalizer %q", spec)
+}
+
+// Helper function to wrap a string transformation as a normalizer
+func mapString(fn func(string) string) normalizer {
+	return func(val interface{}) interface{} {
+		if s, ok := stringValue(val); ok {
+			return fn(s)
+		}
+		return val
+	}
+}
+
+// Function to get the normalizers that apply to a table column
+func (s *NormalizerSet) forColumn(tableName, column string) []normalizer {
+	if s == nil || len(s.rules) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cacheKey := tableName + "." + column
+	if funcs, ok := s.cache[cacheKey]; ok {
+		return funcs
+	}
+
+	var funcs []normalizer
+	for _, rule := range s.rules {
+		if matchColumnPattern(rule.pattern, tableName, column) {
+			funcs = append(funcs, rule.funcs...)
+		}
+	}
+
+	s.cache[cacheKey] = funcs
+	return funcs
+}
+
+// Function to return a normalized copy of a row for the given table
+func (s *NormalizerSet) normalizeRow(tableName string, row map[string]interface{}) map[string]interface{} {
+	if s == nil || len(s.rules) == 0 {
+		return row
+	}
+
+	normalized := make(map[string]interface{}, len(row))
+	for col, val := range row {
+		for _, fn := range s.forColumn(tableName, col) {
+			val = fn(val)
+		}
+		normalized[col] = val
+	}
+
+	return normalized
+}
+
+// Helper function to check whether a "column" or "table.column" pattern
+// matches the given column
+func matchColumnPattern(pattern, tableName, column string) bool {
+	if tablePattern, columnPattern, ok := strings.Cut(pattern, "."); ok {
+		tableOk, _ := path.Match(tablePattern, tableName)
+		columnOk, _ := path.Match(columnPattern, column)
+		return tableOk && columnOk
+	}
+
+	matched, _ := path.Match(pattern, column)
+	return matched
+}
+
+// Helper function to get the string content of a text value
+func stringValue(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+// Helper function to get the numeric content of a value
+func floatValue(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string, []byte:
+		s, _ := stringValue(v)
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Helper function to get the timestamp content of a value
+func timeValue(val interface{}) (time.Time, bool) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v != nil {
+			return *v, true
+		}
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Function to validate a timestamp truncation unit
+func parseTruncateUnit(unit string) (string, error) {
+	switch strings.ToLower(unit) {
+	case "ms", "millisecond":
+		return "millisecond", nil
+	case "s", "second":
+		return "second", nil
+	case "m", "minute":
+		return "minute", nil
+	case "h", "hour":
+		return "hour", nil
+	case "d", "day":
+		return "day", nil
+	}
+	return "", fmt.Errorf("truncate requires a unit (millisecond, second, minute, hour, day), got %q", unit)
+}
+
+// Helper function to truncate a timestamp to the given unit
+func truncateTime(t time.Time, unit string) time.Time {
+	switch unit {
+	case "millisecond":
+		return t.Truncate(time.Millisecond)
+	case "second":
+		return t.Truncate(time.Second)
+	case "minute":
+		return t.Truncate(time.Minute)
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewNormalizer(t *testing.T) {
+	ts := time.Date(2024, 3, 5, 10, 20, 30, 123456789, time.UTC)
+
+	tests := []struct {
+		spec string
+		in   interface{}
+		want interface{}
+	}{
+		{"trim", "  a b  ", "a b"},
+		{"trim", []byte(" x "), "x"},
+		{"trim", 42, 42},
+		{"lower", "MiXeD", "mixed"},
+		{"ci", "ABC", "abc"},
+		{"line_endings", "a\r\nb\rc", "a\nb\nc"},
+		{"null_empty", "", nil},
+		{"null_empty", "x", "x"},
+		{"null_empty", nil, nil},
+		{"round:2", 1.005001, "1.01"},
+		{"round:0", "2.4", "2"},
+		{"round:1", float32(1.3), "1.3"},
+		{"round:2", "abc", "abc"},
+		{"truncate:second", ts, time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC)},
+		{"truncate:ms", ts, time.Date(2024, 3, 5, 10, 20, 30, 123000000, time.UTC)},
+		{"truncate:day", ts, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{" Truncate : h ", "2024-03-05 10:20:30", time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		fn, err := newNormalizer(tt.spec)
+		if err != nil {
+			t.Fatalf("newNormalizer(%q): %v", tt.spec, err)
+		}
+		if got := fn(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("newNormalizer(%q)(%#v) = %#v, want %#v", tt.spec, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewNormalizerErrors(t *testing.T) {
+	for _, spec := range []string{"", "upper", "round", "round:-1", "round:x", "truncate", "truncate:week"} {
+		if _, err := newNormalizer(spec); err == nil {
+			t.Errorf("newNormalizer(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseNormalizersErrors(t *testing.T) {
+	tests := []map[string][]string{
+		{"[": {"trim"}},
+		{"users.email": {"trim", "nope"}},
+	}
+
+	for _, spec := range tests {
+		if _, err := parseNormalizers(spec); err == nil {
+			t.Errorf("parseNormalizers(%v) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestNormalizeRow(t *testing.T) {
+	set, err := parseNormalizers(map[string][]string{
+		"users.email": {"lower"},
+		"*":           {"trim"},
+		"*.note":      {"null_empty"},
+		"price":       {"round:1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		table string
+		row   map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			// generic trim runs before the table-specific lower
+			"users",
+			map[string]interface{}{"email": "  A@B.COM ", "note": "  ", "id": 1},
+			map[string]interface{}{"email": "a@b.com", "note": nil, "id": 1},
+		},
+		{
+			"orders",
+			map[string]interface{}{"email": " X@Y ", "price": 1.26},
+			map[string]interface{}{"email": "X@Y", "price": "1.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := set.normalizeRow(tt.table, tt.row); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizeRow(%q, %v) = %v, want %v", tt.table, tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRowWithoutRules(t *testing.T) {
+	row := map[string]interface{}{"a": " x "}
+
+	var set *NormalizerSet
+	if got := set.normalizeRow("t", row); !reflect.DeepEqual(got, row) {
+		t.Errorf("nil set changed the row: %v", got)
+	}
+}
+
+func TestMatchColumnPattern(t *testing.T) {
+	tests := []struct {
+		pattern, table, column string
+		want                   bool
+	}{
+		{"email", "users", "email", true},
+		{"email", "users", "emails", false},
+		{"*_at", "orders", "created_at", true},
+		{"users.email", "users", "email", true},
+		{"users.email", "admins", "email", false},
+		{"user?.*", "users", "id", true},
+		{"[a-c]*.id", "orders", "id", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchColumnPattern(tt.pattern, tt.table, tt.column); got != tt.want {
+			t.Errorf("matchColumnPattern(%q, %q, %q) = %t, want %t", tt.pattern, tt.table, tt.column, got, tt.want)
+		}
+	}
+}
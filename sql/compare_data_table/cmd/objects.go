@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Status of an object difference
+const (
+	objectOnlyInDev     = "Only in Dev"
+	objectOnlyInStaging = "Only in Staging"
+	objectDifferent     = "Different"
+	objectBehindTable   = "Behind Table"
+)
+
+// A named attribute of a database object, compared as text
+type objectAttribute struct {
+	Name  string
+	Value string
+}
+
+// A database object other than table data (sequence, enum, view, function, ...)
+// described by its attributes
+type dbObject struct {
+	Kind       string
+	Name       string
+	Attributes []objectAttribute
+
+	// Problem found on one side regardless of the other, e.g. a sequence
+	// behind the max id of its table (empty when none)
+	Problem string
+}
+
+// Function to get the value of an attribute ("" when the object doesn't have it)
+func (o dbObject) attribute(name string) string {
+	for _, attr := range o.Attributes {
+		if attr.Name == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// A difference between the objects of both environments, one row of the
+// Objects sheet
+type objectDiff struct {
+	Kind      string
+	Name      string
+	Attribute string
+	Dev       string
+	Staging   string
+	Status    string
+	Details   string
+}
+
+// A kind of database object that can be compared with -objects
+type objectKind struct {
+	Name string
+	load func(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error)
+
+	// Optional explanation of a difference in an attribute
+	explain func(attribute, dev, staging string) string
+}
+
+// Object kinds in the order they are compared and reported
+var objectKinds = []objectKind{
+	{Name: "sequences", load: loadSequences},
+	{Name: "enums", load: loadEnums, explain: explainEnumLabels},
+	{Name: "domains", load: loadDomains},
+	{Name: "views", load: loadViews, explain: explainDefinition},
+	{Name: "matviews", load: loadMaterializedViews, explain: explainDefinition},
+	{Name: "functions", load: loadFunctions, explain: explainDefinition},
+	{Name: "triggers", load: loadTriggers, explain: explainDefinition},
+	{Name: "policies", load: loadPolicies, explain: explainDefinition},
+	{Name: "extensions", load: loadExtensions},
+}
+
+// Function to parse the -objects list into object kinds. "all" selects every kind.
+func parseObjectKinds(list string) ([]objectKind, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	var kinds []objectKind
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			return objectKinds, nil
+		}
+
+		found := false
+		for _, kind := range objectKinds {
+			if kind.Name == name {
+				if !containsObjectKind(kinds, name) {
+					kinds = append(kinds, kind)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown object kind %q: expected all or %s", name, strings.Join(objectKindNames(objectKinds), ", "))
+		}
+	}
+	return kinds, nil
+}
+
+func containsObjectKind(kinds []objectKind, name string) bool {
+	for _, kind := range kinds {
+		if kind.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to get the names of object kinds
+func objectKindNames(kinds []objectKind) []string {
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = kind.Name
+	}
+	return names
+}
+
+// Function to compare the objects of the given kinds between the dev schema
+// and the staging schema. Only differences are returned. A kind that can't be
+// read is skipped and its error returned, one line per kind; each kind is
+// read inside savepoints, so a failure doesn't affect the other kinds.
+func compareObjects(ctx context.Context, devReader, stagingReader *snapshotReader, devSchema, stagingSchema string, kinds []objectKind) ([]objectDiff, []string) {
+	devDB, stagingDB := devReader.conn(), stagingReader.conn()
+
+	var diffs []objectDiff
+	var failures []string
+	for _, kind := range kinds {
+		if ctx.Err() != nil {
+			failures = append(failures, fmt.Sprintf("%s: not compared (%v)", kind.Name, ctx.Err()))
+			continue
+		}
+
+		err := withSavepoints(devReader, stagingReader, devDB, stagingDB, func() error {
+			devObjects, err := kind.load(ctx, devDB, devSchema)
+			if err != nil {
+				return fmt.Errorf("failed to read dev %s: %w", kind.Name, err)
+			}
+			stagingObjects, err := kind.load(ctx, stagingDB, stagingSchema)
+			if err != nil {
+				return fmt.Errorf("failed to read staging %s: %w", kind.Name, err)
+			}
+			diffs = append(diffs, diffObjects(kind, devObjects, stagingObjects)...)
+			return nil
+		})
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", kind.Name, err))
+		}
+	}
+	return diffs, failures
+}
+
+// Function to compare the objects of one kind, matched by name
+func diffObjects(kind objectKind, devObjects, stagingObjects []dbObject) []objectDiff {
+	devByName := make(map[string]dbObject)
+	for _, obj := range devObjects {
+		devByName[obj.Name] = obj
+	}
+	stagingByName := make(map[string]dbObject)
+	for _, obj := range stagingObjects {
+		stagingByName[obj.Name] = obj
+	}
+
+	names := make([]string, 0, len(devByName)+len(stagingByName))
+	for name := range devByName {
+		names = append(names, name)
+	}
+	for name := range stagingByName {
+		if _, ok := devByName[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []objectDiff
+	for _, name := range names {
+		devObj, inDev := devByName[name]
+		stagingObj, inStaging := stagingByName[name]
+
+		switch {
+		case !inStaging:
+			diffs = append(diffs, objectDiff{Kind: devObj.Kind, Name: name, Status: objectOnlyInDev})
+		case !inDev:
+			diffs = append(diffs, objectDiff{Kind: stagingObj.Kind, Name: name, Status: objectOnlyInStaging})
+		default:
+			for _, attr := range devObj.Attributes {
+				stagingValue := stagingObj.attribute(attr.Name)
+				if attr.Value == stagingValue {
+					continue
+				}
+				diff := objectDiff{
+					Kind:      devObj.Kind,
+					Name:      name,
+					Attribute: attr.Name,
+					Dev:       attr.Value,
+					Staging:   stagingValue,
+					Status:    objectDifferent,
+				}
+				if kind.explain != nil {
+					diff.Details = kind.explain(attr.Name, attr.Value, stagingValue)
+				}
+				diffs = append(diffs, diff)
+			}
+		}
+
+		// Problems are reported per side, also for objects on one side only
+		if inDev && devObj.Problem != "" {
+			diffs = append(diffs, objectDiff{Kind: devObj.Kind, Name: name, Status: objectBehindTable, Dev: devObj.Problem})
+		}
+		if inStaging && stagingObj.Problem != "" {
+			diffs = append(diffs, objectDiff{Kind: stagingObj.Kind, Name: name, Status: objectBehindTable, Staging: stagingObj.Problem})
+		}
+	}
+	return diffs
+}
+
+// A row of pg_sequences with the column owning the sequence (serial or
+// identity), if any
+type sequenceInfo struct {
+	Name        string
+	DataType    string
+	StartValue  int64
+	MinValue    int64
+	MaxValue    int64
+	IncrementBy int64
+	Cycle       bool
+	LastValue   *int64 // NULL when the sequence was never used, or can't be read
+	Readable    bool   // whether the current user may read the last value (USAGE or SELECT)
+	OwnerTable  *string
+	OwnerColumn *string
+}
+
+// Function to show the last value of a sequence. pg_sequences shows NULL
+// both for an unused sequence and for one the user has no privilege on.
+func (s sequenceInfo) lastValueText() string {
+	switch {
+	case !s.Readable:
+		return "(no privilege)"
+	case s.LastValue == nil:
+		return "(not used)"
+	}
+	return strconv.FormatInt(*s.LastValue, 10)
+}
+
+// Function to check whether a sequence can be behind the column it feeds.
+// Only readable, ascending sequences owned by a column are checked; without
+// the privilege the last value is unknown, which isn't a problem to report.
+func (s sequenceInfo) checksBehind() bool {
+	return s.Readable && s.IncrementBy > 0 && s.OwnerTable != nil && s.OwnerColumn != nil
+}
+
+// Function to get the value an ascending sequence hands out next: the start
+// value when it was never called (last_value is NULL), otherwise the last
+// value plus the increment. Not ok when the sequence is exhausted.
+func (s sequenceInfo) nextValue() (int64, bool) {
+	if s.LastValue == nil {
+		return s.StartValue, true
+	}
+	if *s.LastValue > s.MaxValue-s.IncrementBy {
+		return 0, false
+	}
+	return *s.LastValue + s.IncrementBy, true
+}
+
+// Function to read the sequences of a schema. A sequence is flagged when its
+// next value is not above the max value of the column it feeds, so the next
+// insert would fail on a duplicate key (typical after a data sync).
+func loadSequences(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	db = db.WithContext(ctx)
+
+	var sequences []sequenceInfo
+	err := db.Raw(`
+		SELECT s.sequencename AS name,
+			s.data_type::text AS data_type,
+			s.start_value, s.min_value, s.max_value, s.increment_by,
+			s.cycle, s.last_value,
+			has_sequence_privilege(c.oid, 'SELECT, USAGE') AS readable,
+			t.relname AS owner_table,
+			a.attname AS owner_column
+		FROM pg_sequences s
+		JOIN pg_namespace n ON n.nspname = s.schemaname
+		JOIN pg_class c ON c.relnamespace = n.oid AND c.relname = s.sequencename
+		LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = c.oid
+			AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+		LEFT JOIN pg_class t ON t.oid = d.refobjid
+		LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+		WHERE s.schemaname = ?
+		ORDER BY s.sequencename
+	`, schema).Scan(&sequences).Error
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]dbObject, 0, len(sequences))
+	for _, seq := range sequences {
+		ownedBy := ""
+		if seq.OwnerTable != nil && seq.OwnerColumn != nil {
+			ownedBy = *seq.OwnerTable + "." + *seq.OwnerColumn
+		}
+
+		obj := dbObject{
+			Kind: "sequence",
+			Name: seq.Name,
+			Attributes: []objectAttribute{
+				{"data type", seq.DataType},
+				{"increment", strconv.FormatInt(seq.IncrementBy, 10)},
+				{"start", strconv.FormatInt(seq.StartValue, 10)},
+				{"min", strconv.FormatInt(seq.MinValue, 10)},
+				{"max", strconv.FormatInt(seq.MaxValue, 10)},
+				{"cycle", strconv.FormatBool(seq.Cycle)},
+				{"owned by", ownedBy},
+				{"last value", seq.lastValueText()},
+			},
+		}
+
+		// Only ascending sequences feeding a column can be behind its max
+		if seq.checksBehind() {
+			var maxValue *int64
+			query := fmt.Sprintf("SELECT max(%s)::bigint FROM %s.%s",
+				quoteIdent(*seq.OwnerColumn), quoteIdent(schema), quoteIdent(*seq.OwnerTable))
+			if err := db.Raw(query).Scan(&maxValue).Error; err != nil {
+				return nil, fmt.Errorf("failed to get max of %s: %w", ownedBy, err)
+			}
+			if maxValue != nil {
+				if next, ok := seq.nextValue(); ok && next <= *maxValue {
+					obj.Problem = fmt.Sprintf("next value %d <= max(%s) %d", next, ownedBy, *maxValue)
+				}
+			}
+		}
+
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// Function to read the enum types of a schema with their labels in sort order
+func loadEnums(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	var labels []struct {
+		Name  string
+		Label string
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT t.typname AS name, e.enumlabel AS label
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		WHERE n.nspname = ?
+		ORDER BY t.typname, e.enumsortorder
+	`, schema).Scan(&labels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []dbObject
+	var current []string
+	for i, label := range labels {
+		current = append(current, quoteLabel(label.Label))
+		if i == len(labels)-1 || labels[i+1].Name != label.Name {
+			objects = append(objects, dbObject{
+				Kind:       "enum",
+				Name:       label.Name,
+				Attributes: []objectAttribute{{"labels", strings.Join(current, ", ")}},
+			})
+			current = nil
+		}
+	}
+	return objects, nil
+}
+
+// Function to quote an enum label so a list of labels can be split again
+func quoteLabel(label string) string {
+	return "'" + strings.ReplaceAll(label, "'", "''") + "'"
+}
+
+// Function to split a list of quoted enum labels
+func splitLabels(list string) []string {
+	var labels []string
+	var current strings.Builder
+	quoted := false
+	for i := 0; i < len(list); i++ {
+		ch := list[i]
+		switch {
+		case ch == '\'' && quoted && i+1 < len(list) && list[i+1] == '\'':
+			current.WriteByte('\'')
+			i++
+		case ch == '\'':
+			quoted = !quoted
+			if !quoted {
+				labels = append(labels, current.String())
+				current.Reset()
+			}
+		case quoted:
+			current.WriteByte(ch)
+		}
+	}
+	return labels
+}
+
+// Function to explain a difference in enum labels: labels missing on either
+// side, or the same labels in another order
+func explainEnumLabels(attribute, dev, staging string) string {
+	devLabels, stagingLabels := splitLabels(dev), splitLabels(staging)
+
+	var parts []string
+	if onlyDev := subtractStrings(devLabels, stagingLabels); len(onlyDev) > 0 {
+		parts = append(parts, "only in dev: "+strings.Join(onlyDev, ", "))
+	}
+	if onlyStaging := subtractStrings(stagingLabels, devLabels); len(onlyStaging) > 0 {
+		parts = append(parts, "only in staging: "+strings.Join(onlyStaging, ", "))
+	}
+	if len(parts) == 0 {
+		return "same labels in a different order"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Function to get the values of a that are not in b
+func subtractStrings(a, b []string) []string {
+	var result []string
+	for _, value := range a {
+		if !containsString(b, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// Function to read the domain types of a schema with their base type,
+// default, NOT NULL and check constraints
+func loadDomains(ctx context.Context, db *gorm.DB, schema string) ([]dbObject, error) {
+	var domains []struct {
+		Name        string
+		BaseType    string
+		NotNull     bool
+		DefaultExpr *string
+		Constraints *string
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT t.typname AS name,
+			format_type(t.typbasetype, t.typtypmod) AS base_type,
+			t.typnotnull AS not_null,
+			t.typdefault AS default_expr,
+			(SELECT string_agg(pg_get_constraintdef(c.oid), ' AND ' ORDER BY pg_get_constraintdef(c.oid))
+				FROM pg_constraint c WHERE c.contypid = t.oid) AS constraints
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = ?
+		AND t.typtype = 'd'
+		ORDER BY t.typname
+	`, schema).Scan(&domains).Error
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]dbObject, 0, len(domains))
+	for _, domain := range domains {
+		defaultExpr, constraints := "", ""
+		if domain.DefaultExpr != nil {
+			defaultExpr = *domain.DefaultExpr
+		}
+		if domain.Constraints != nil {
+			constraints = *domain.Constraints
+		}
+		objects = append(objects, dbObject{
+			Kind: "domain",
+			Name: domain.Name,
+			Attributes: []objectAttribute{
+				{"base type", domain.BaseType},
+				{"not null", strconv.FormatBool(domain.NotNull)},
+				{"default", defaultExpr},
+				{"constraints", constraints},
+			},
+		})
+	}
+	return objects, nil
+}
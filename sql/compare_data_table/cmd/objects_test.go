@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitLabels(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"", nil},
+		{"'new'", []string{"new"}},
+		{"'new', 'open', 'done'", []string{"new", "open", "done"}},
+		{"'a, b', 'c'", []string{"a, b", "c"}},
+		{"'it''s', ''''", []string{"it's", "'"}},
+		{"''", []string{""}},
+	}
+
+	for _, tt := range tests {
+		if got := splitLabels(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitLabels(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestSplitLabelsRoundTrip(t *testing.T) {
+	labels := []string{"plain", "with space", "comma, inside", "quote's", ""}
+
+	quoted := make([]string, len(labels))
+	for i, label := range labels {
+		quoted[i] = quoteLabel(label)
+	}
+	if got := splitLabels(strings.Join(quoted, ", ")); !reflect.DeepEqual(got, labels) {
+		t.Errorf("splitLabels of quoted labels = %q, want %q", got, labels)
+	}
+}
+
+func TestExplainEnumLabels(t *testing.T) {
+	tests := []struct {
+		dev, staging, want string
+	}{
+		{"'a', 'b'", "'b', 'a'", "same labels in a different order"},
+		{"'a', 'b', 'c'", "'a'", "only in dev: b, c"},
+		{"'a'", "'a', 'x'", "only in staging: x"},
+		{"'a', 'b'", "'a', 'c'", "only in dev: b; only in staging: c"},
+	}
+
+	for _, tt := range tests {
+		if got := explainEnumLabels("labels", tt.dev, tt.staging); got != tt.want {
+			t.Errorf("explainEnumLabels(%q, %q) = %q, want %q", tt.dev, tt.staging, got, tt.want)
+		}
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	kind := objectKind{Name: "enums", explain: explainEnumLabels}
+	enum := func(name, labels string) dbObject {
+		return dbObject{Kind: "enum", Name: name, Attributes: []objectAttribute{{"labels", labels}}}
+	}
+
+	dev := []dbObject{
+		enum("status", "'new', 'done'"),
+		enum("same", "'x'"),
+		enum("dev_only", "'a'"),
+		{Kind: "sequence", Name: "users_id_seq", Problem: "next value 3 <= max(id) 9"},
+	}
+	staging := []dbObject{
+		enum("status", "'new', 'open', 'done'"),
+		enum("same", "'x'"),
+		enum("staging_only", "'b'"),
+		{Kind: "sequence", Name: "users_id_seq"},
+	}
+
+	want := []objectDiff{
+		{Kind: "enum", Name: "dev_only", Status: objectOnlyInDev},
+		{Kind: "enum", Name: "staging_only", Status: objectOnlyInStaging},
+		{Kind: "enum", Name: "status", Attribute: "labels", Dev: "'new', 'done'", Staging: "'new', 'open', 'done'", Status: objectDifferent, Details: "only in staging: open"},
+		{Kind: "sequence", Name: "users_id_seq", Status: objectBehindTable, Dev: "next value 3 <= max(id) 9"},
+	}
+	if got := diffObjects(kind, dev, staging); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffObjects = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseObjectKinds(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{}, false},
+		{"Sequences, enums,,sequences", []string{"sequences", "enums"}, false},
+		{"domains,all", objectKindNames(objectKinds), false},
+		{"tables", nil, true},
+	}
+
+	for _, tt := range tests {
+		kinds, err := parseObjectKinds(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseObjectKinds(%q) error = %v, want error %t", tt.list, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got := objectKindNames(kinds); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseObjectKinds(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestSequenceNextValue(t *testing.T) {
+	value := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name     string
+		sequence sequenceInfo
+		want     int64
+		wantOk   bool
+	}{
+		{"never used", sequenceInfo{StartValue: 100, IncrementBy: 1, MaxValue: math.MaxInt64}, 100, true},
+		{"used", sequenceInfo{StartValue: 1, IncrementBy: 5, MaxValue: math.MaxInt64, LastValue: value(20)}, 25, true},
+		{"descending", sequenceInfo{StartValue: -1, IncrementBy: -1, MaxValue: -1, LastValue: value(-7)}, -8, true},
+		{"at max", sequenceInfo{StartValue: 1, IncrementBy: 1, MaxValue: 32767, LastValue: value(32767)}, 0, false},
+		{"no overflow", sequenceInfo{StartValue: 1, IncrementBy: 10, MaxValue: math.MaxInt64, LastValue: value(math.MaxInt64 - 5)}, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.sequence.nextValue()
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("%s: nextValue = %d, %t, want %d, %t", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestSequenceReadability(t *testing.T) {
+	value := func(v int64) *int64 { return &v }
+	table, column := "users", "id"
+
+	tests := []struct {
+		name         string
+		sequence     sequenceInfo
+		wantLast     string
+		wantChecking bool
+	}{
+		{"used", sequenceInfo{Readable: true, IncrementBy: 1, LastValue: value(42), OwnerTable: &table, OwnerColumn: &column}, "42", true},
+		{"never used", sequenceInfo{Readable: true, IncrementBy: 1, OwnerTable: &table, OwnerColumn: &column}, "(not used)", true},
+		// pg_sequences hides last_value without USAGE or SELECT; that is no sign of being behind
+		{"no privilege", sequenceInfo{IncrementBy: 1, OwnerTable: &table, OwnerColumn: &column}, "(no privilege)", false},
+		{"not owned", sequenceInfo{Readable: true, IncrementBy: 1, LastValue: value(1)}, "1", false},
+		{"descending", sequenceInfo{Readable: true, IncrementBy: -1, LastValue: value(-3), OwnerTable: &table, OwnerColumn: &column}, "-3", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.sequence.lastValueText(); got != tt.wantLast {
+			t.Errorf("%s: lastValueText = %q, want %q", tt.name, got, tt.wantLast)
+		}
+		if got := tt.sequence.checksBehind(); got != tt.wantChecking {
+			t.Errorf("%s: checksBehind = %t, want %t", tt.name, got, tt.wantChecking)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/Alwanly/script-tools/sql/dbconfig"
+)
+
+// Flags selecting the environments to compare from the profiles file
+type profileFlags struct {
+	source   *string
+	target   *string
+	profiles *string
+}
+
+// Function to register the -source, -target and -profiles flags on a flag set
+func newProfileFlags(fs *flag.FlagSet) *profileFlags {
+	return &profileFlags{
+		source:   fs.String("source", "", "Named environment from the profiles file used as the dev side (default: DEV_DB_* variables)"),
+		target:   fs.String("target", "", "Named environment from the profiles file used as the staging side (default: STAGING_DB_* variables)"),
+		profiles: fs.String("profiles", "", "Environment profiles file (default: $SCRIPT_TOOLS_ENVIRONMENTS or ~/"+dbconfig.DefaultProfilesFile+")"),
+	}
+}
+
+// Function to resolve the dev and staging connection settings, from the
+// profiles file when -source/-target name an environment and from the
+// DEV_DB_*/STAGING_DB_* variables otherwise
+func (p *profileFlags) resolve() (DBConfig, DBConfig, error) {
+	devConfig, err := p.config(*p.source, "DEV")
+	if err != nil {
+		return DBConfig{}, DBConfig{}, err
+	}
+	stagingConfig, err := p.config(*p.target, "STAGING")
+	if err != nil {
+		return DBConfig{}, DBConfig{}, err
+	}
+	return devConfig, stagingConfig, nil
+}
+
+// Helper function to get the settings of one side
+func (p *profileFlags) config(name, envPrefix string) (DBConfig, error) {
+	if name == "" {
+		return envDBConfig(envPrefix), nil
+	}
+
+	path := *p.profiles
+	if path == "" {
+		path = dbconfig.ProfilesPath()
+	}
+	return dbconfig.LoadProfile(path, name)
+}
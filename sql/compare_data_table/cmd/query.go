@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Name used for a query given on the command line with -query
+const inlineQueryName = "query"
+
+// A SELECT compared between the databases in query mode. Rows are matched on
+// the key columns, which must be columns of the result.
+type namedQuery struct {
+	Name string
+	SQL  string
+	Key  []string
+}
+
+// File with named queries (-queries), for example:
+//
+//	queries:
+//	  permissions_per_role:
+//	    key: [role_code]
+//	    sql: |
+//	      SELECT role_code, count(*) AS permissions
+//	      FROM role_permissions
+//	      GROUP BY role_code
+type queryFile struct {
+	Queries map[string]struct {
+		SQL string   `yaml:"sql"`
+		Key []string `yaml:"key"`
+	} `yaml:"queries"`
+}
+
+// Function to load named queries from a file. With names only those queries
+// are returned, in the given order; otherwise all of them sorted by name.
+func loadQueries(path string, names []string) ([]namedQuery, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queries file %s: %w", path, err)
+	}
+
+	var file queryFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse queries file %s: %w", path, err)
+	}
+
+	if len(names) == 0 {
+		for name := range file.Queries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var queries []namedQuery
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		q, ok := file.Queries[name]
+		if !ok {
+			return nil, fmt.Errorf("query %q not found in %s", name, path)
+		}
+		query := namedQuery{Name: name, SQL: q.SQL, Key: q.Key}
+		if err := query.validate(); err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+
+	return queries, nil
+}
+
+// Function to index queries by name. Names must be unique, since results
+// and checkpoints are kept per name.
+func queriesByName(queries []namedQuery) (map[string]namedQuery, error) {
+	byName := make(map[string]namedQuery)
+	for _, query := range queries {
+		if _, ok := byName[query.Name]; ok {
+			return nil, fmt.Errorf("query %s is given more than once", query.Name)
+		}
+		byName[query.Name] = query
+	}
+	return byName, nil
+}
+
+// Function to check that a query has SQL and key columns
+func (q namedQuery) validate() error {
+	if strings.TrimSpace(q.SQL) == "" {
+		return fmt.Errorf("query %s has no SQL", q.Name)
+	}
+	if len(q.Key) == 0 {
+		return fmt.Errorf("query %s declares no key columns", q.Name)
+	}
+	return nil
+}
+
+// Function to compare the result of a query in both databases. The result
+// has the same shape as a table comparison, with the query name as table name.
+func compareQuery(ctx context.Context, devDB, stagingDB *gorm.DB, query namedQuery, opts CompareOptions) (map[string]interface{}, error) {
+	devColumns, columnTypes, devData, err := runQuery(devDB.WithContext(ctx), query.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query %s on dev: %w", query.Name, err)
+	}
+	log.Printf("Retrieved %d rows from dev query %s", len(devData), query.Name)
+
+	stagingColumns, _, stagingData, err := runQuery(stagingDB.WithContext(ctx), query.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query %s on staging: %w", query.Name, err)
+	}
+	log.Printf("Retrieved %d rows from staging query %s", len(stagingData), query.Name)
+
+	if strings.Join(devColumns, ",") != strings.Join(stagingColumns, ",") {
+		return nil, fmt.Errorf("query %s returns different columns in dev (%s) and staging (%s)",
+			query.Name, strings.Join(devColumns, ", "), strings.Join(stagingColumns, ", "))
+	}
+	for _, key := range query.Key {
+		if !containsString(devColumns, key) {
+			return nil, fmt.Errorf("key column %s is not returned by query %s (columns: %s)",
+				key, query.Name, strings.Join(devColumns, ", "))
+		}
+	}
+
+	result := diffRows(query.Name, devColumns, columnTypes, query.Key, devData, stagingData,
+		int64(len(devData)), int64(len(stagingData)), opts)
+	result["query"] = query.SQL
+	return result, nil
+}
+
+// Function to run a query inside a READ ONLY transaction, so a query that
+// would change data is refused by the database
+func runQuery(db *gorm.DB, query string) ([]string, map[string]string, []map[string]interface{}, error) {
+	var columns []string
+	var columnTypes map[string]string
+	var data []map[string]interface{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		columns, columnTypes, data, err = readQueryRows(tx, query)
+		return err
+	}, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return columns, columnTypes, data, nil
+}
+
+// Function to read all rows of a query, keeping the column order and the
+// PostgreSQL type of every column
+func readQueryRows(db *gorm.DB, query string) ([]string, map[string]string, []map[string]interface{}, error) {
+	rows, err := db.Raw(query).Rows()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	columns := make([]string, len(types))
+	columnTypes := make(map[string]string)
+	for i, t := range types {
+		if _, ok := columnTypes[t.Name()]; ok {
+			return nil, nil, nil, fmt.Errorf("column %s is returned more than once; give the columns distinct aliases", t.Name())
+		}
+		columns[i] = t.Name()
+		columnTypes[t.Name()] = queryColumnType(t.DatabaseTypeName())
+	}
+
+	var data []map[string]interface{}
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := db.ScanRows(rows, &row); err != nil {
+			return nil, nil, nil, err
+		}
+		data = append(data, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return columns, columnTypes, data, nil
+}
+
+// Function to translate a driver type name (INT4, TIMESTAMPTZ, ...) to the
+// information_schema data type used for table columns, so query results are
+// formatted like table data
+func queryColumnType(typeName string) string {
+	switch strings.ToUpper(typeName) {
+	case "INT2":
+		return "smallint"
+	case "INT4":
+		return "integer"
+	case "INT8":
+		return "bigint"
+	case "NUMERIC":
+		return "numeric"
+	case "FLOAT4":
+		return "real"
+	case "FLOAT8":
+		return "double precision"
+	case "MONEY":
+		return "money"
+	case "BOOL":
+		return "boolean"
+	case "DATE":
+		return "date"
+	case "TIMESTAMP":
+		return "timestamp without time zone"
+	case "TIMESTAMPTZ":
+		return "timestamp with time zone"
+	case "JSON":
+		return "json"
+	case "JSONB":
+		return "jsonb"
+	case "VARCHAR":
+		return "character varying"
+	case "BPCHAR":
+		return "character"
+	}
+	return strings.ToLower(typeName)
+}
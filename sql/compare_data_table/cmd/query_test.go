@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.yaml")
+	content := `queries:
+  roles:
+    key: [code]
+    sql: SELECT code, name FROM roles
+  per_role:
+    key: [role_code]
+    sql: SELECT role_code, count(*) AS n FROM role_permissions GROUP BY role_code
+  broken:
+    sql: SELECT 1
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		names   []string
+		want    []string
+		wantErr string
+	}{
+		{[]string{"roles", " per_role "}, []string{"roles", "per_role"}, ""},
+		{[]string{"missing"}, nil, "not found"},
+		{[]string{"broken"}, nil, "no key columns"},
+		{nil, nil, "no key columns"}, // all queries, including the broken one
+	}
+
+	for _, tt := range tests {
+		queries, err := loadQueries(path, tt.names)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadQueries(%v) error = %v, want %q", tt.names, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("loadQueries(%v): %v", tt.names, err)
+		}
+		var names []string
+		for _, q := range queries {
+			names = append(names, q.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("loadQueries(%v) = %v, want %v", tt.names, names, tt.want)
+		}
+	}
+}
+
+func TestQueriesByName(t *testing.T) {
+	a := namedQuery{Name: "a", SQL: "SELECT 1 AS id", Key: []string{"id"}}
+	b := namedQuery{Name: "b", SQL: "SELECT 2 AS id", Key: []string{"id"}}
+
+	byName, err := queriesByName([]namedQuery{a, b})
+	if err != nil || len(byName) != 2 || byName["b"].SQL != b.SQL {
+		t.Errorf("queriesByName(a, b) = %v, %v, want both queries", byName, err)
+	}
+
+	// The -query name can clash with a named query, and -query-name can repeat a name
+	if _, err := queriesByName([]namedQuery{a, b, a}); err == nil {
+		t.Error("queriesByName(a, b, a) succeeded, want an error for the duplicate name")
+	}
+}
+
+func TestQueryColumnType(t *testing.T) {
+	tests := map[string]string{
+		"INT8":        "bigint",
+		"TIMESTAMPTZ": "timestamp with time zone",
+		"jsonb":       "jsonb",
+		"BPCHAR":      "character",
+		"UUID":        "uuid",
+	}
+	for name, want := range tests {
+		if got := queryColumnType(name); got != want {
+			t.Errorf("queryColumnType(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestResultSettingsIncludeQueries(t *testing.T) {
+	query := namedQuery{Name: "roles", SQL: "SELECT code FROM roles", Key: []string{"code"}}
+	changed := query
+	changed.SQL = "SELECT code FROM roles WHERE active"
+
+	settings := resultSettings("", CompareOptions{}, []namedQuery{query})
+	if !strings.Contains(settings, query.SQL) {
+		t.Errorf("resultSettings = %q, want it to contain the query SQL", settings)
+	}
+	if settings == resultSettings("", CompareOptions{}, []namedQuery{changed}) {
+		t.Error("resultSettings is the same after the query SQL changed")
+	}
+}
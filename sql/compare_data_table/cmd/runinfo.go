@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Tool version, can be set at build time with -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// Information about a comparison run, written to the "Run Info" sheet so a
+// shared report is self-describing
+type RunInfo struct {
+	ToolVersion   string
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	DevConfig     DBConfig
+	StagingConfig DBConfig
+	DevFile       string // master-data file used instead of the dev database
+	StagingFile   string // master-data file used instead of the staging database
+	Consistent    bool   // whether each database was read from a single snapshot
+	Interrupted   string // why the run stopped early (interrupt or timeout), empty when complete
+	NotCompared   []string
+	Resumed       string         // tables taken over from a checkpoint, empty when not resumed
+	Failed        []tableFailure // tables whose comparison failed
+	Mode          string         // -mode with its settings, empty for a full table comparison
+	Objects       []string       // kinds of database objects compared with -objects
+	ObjectErrors  []string       // object kinds that failed, with the error
+	Queries       []namedQuery   // queries compared with -mode=query
+	Mappings      []string       // table and column renames between dev and staging
+	DevSchema     string
+	StagingSchema string
+	SameDatabase  bool              // staging was read from the dev connection
+	SoftDelete    *SoftDeleteConfig // treatment of soft-deleted rows, nil when off
+	Masks         []string          // masking rules of sensitive columns
+	Flags         []string
+}
+
+// Function to describe whether the run compared every table
+func (r *RunInfo) status() string {
+	switch {
+	case r.Interrupted != "" && len(r.Failed) > 0:
+		return fmt.Sprintf("PARTIAL (%s): %d tables not compared, %d failed", r.Interrupted, len(r.NotCompared), len(r.Failed))
+	case r.Interrupted != "":
+		return fmt.Sprintf("PARTIAL (%s): %d tables not compared", r.Interrupted, len(r.NotCompared))
+	case len(r.Failed) > 0:
+		return fmt.Sprintf("INCOMPLETE: %d tables failed", len(r.Failed))
+	}
+	return "Complete"
+}
+
+// Function to describe a non-default -mode for the Run Info sheet
+func describeMode(mode, countMethod, sampleMethod string, sampleRate float64) string {
+	switch mode {
+	case "count":
+		return fmt.Sprintf("count (%s)", countMethod)
+	case "sample":
+		return fmt.Sprintf("sample %g%% of rows (%s)", sampleRate*100, sampleMethod)
+	case "query":
+		return "query"
+	}
+	return ""
+}
+
+// Function to get the tool version, including the VCS revision when the
+// binary was built from a git checkout
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+
+	if revision == "" {
+		return version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return fmt.Sprintf("%s (%s)", version, revision)
+}
+
+// Function to list the command-line flags that were explicitly set
+func collectFlags() []string {
+	var flags []string
+	flag.Visit(func(fl *flag.Flag) {
+		flags = append(flags, fmt.Sprintf("-%s=%s", fl.Name, fl.Value.String()))
+	})
+	return flags
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestRunInfoStatus(t *testing.T) {
+	failed := []tableFailure{{Table: "users", Error: "permission denied"}}
+
+	tests := []struct {
+		name string
+		info RunInfo
+		want string
+	}{
+		{"complete", RunInfo{}, "Complete"},
+		{"interrupted", RunInfo{Interrupted: "interrupted", NotCompared: []string{"a", "b"}}, "PARTIAL (interrupted): 2 tables not compared"},
+		{"failed", RunInfo{Failed: failed}, "INCOMPLETE: 1 tables failed"},
+		{"both", RunInfo{Interrupted: "timeout after 1m0s", NotCompared: []string{"a"}, Failed: failed}, "PARTIAL (timeout after 1m0s): 1 tables not compared, 1 failed"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.info.status(); got != tt.want {
+			t.Errorf("%s: status() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Resolution of the sample rate: rows are kept when their key hash modulo
+// this falls below rate * sampleBuckets
+const sampleBuckets = 1000000
+
+// Function to parse a sample rate given as a percentage ("1%", "0.5%") or a
+// fraction ("0.01")
+func parseSampleRate(text string) (float64, error) {
+	text = strings.TrimSpace(text)
+	percent := strings.HasSuffix(text, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(text, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sample rate %q: expected a percentage like 1%% or a fraction like 0.01", text)
+	}
+	if percent {
+		rate /= 100
+	}
+	if rate <= 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid sample rate %q: must be above 0%% and at most 100%%", text)
+	}
+	return rate, nil
+}
+
+// Function to get the salt of the key hash for a sample method. "hash"
+// picks the same rows on every run; "random" picks other rows each run. Both
+// sides always use the same salt, so they sample the same keys.
+func sampleSalt(method string) (string, error) {
+	switch method {
+	case "hash":
+		return "", nil
+	case "random":
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to create sample seed: %w", err)
+		}
+		return hex.EncodeToString(b), nil
+	}
+	return "", fmt.Errorf("invalid sample method %q: expected 'hash' or 'random'", method)
+}
+
+// Function to build the SQL condition selecting a sample of rows by the hash
+// of their key columns. columnName translates a key column to its name in the
+// queried table.
+func sampleCondition(keyColumns []string, rate float64, salt string, columnName func(string) string) string {
+	parts := make([]string, len(keyColumns))
+	for i, col := range keyColumns {
+		parts[i] = fmt.Sprintf("%s::text", columnName(col))
+	}
+	return fmt.Sprintf("abs(hashtext(concat_ws('|', %s) || %s)::bigint) %% %d < %d",
+		strings.Join(parts, ", "), sqlLiteral(salt), sampleBuckets, int64(math.Round(rate*sampleBuckets)))
+}
+
+// Helper function to combine optional conditions with AND
+func andConditions(conditions ...string) string {
+	var parts []string
+	for _, condition := range conditions {
+		if condition != "" {
+			parts = append(parts, condition)
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
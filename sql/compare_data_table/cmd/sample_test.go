@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func TestParseSampleRate(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    float64
+		wantErr bool
+	}{
+		{"1%", 0.01, false},
+		{" 0.5% ", 0.005, false},
+		{"100%", 1, false},
+		{"0.25", 0.25, false},
+		{"1", 1, false},
+		{"0", 0, true},
+		{"0%", 0, true},
+		{"-1%", 0, true},
+		{"150%", 0, true},
+		{"2", 0, true},
+		{"", 0, true},
+		{"ten%", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSampleRate(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSampleRate(%q) error = %v, want error %t", tt.text, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSampleRate(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSampleSalt(t *testing.T) {
+	if salt, err := sampleSalt("hash"); err != nil || salt != "" {
+		t.Errorf("sampleSalt(hash) = %q, %v, want a fixed empty salt", salt, err)
+	}
+
+	first, err := sampleSalt("random")
+	if err != nil || len(first) != 16 {
+		t.Fatalf("sampleSalt(random) = %q, %v", first, err)
+	}
+	if second, _ := sampleSalt("random"); second == first {
+		t.Errorf("sampleSalt(random) returned %q twice", first)
+	}
+
+	if _, err := sampleSalt("first"); err == nil {
+		t.Error("sampleSalt(first) succeeded, want an error")
+	}
+}
+
+func TestSampleCondition(t *testing.T) {
+	quote := func(col string) string { return `"` + col + `"` }
+
+	tests := []struct {
+		keys []string
+		rate float64
+		salt string
+		want string
+	}{
+		{
+			[]string{"id"}, 0.01, "",
+			`abs(hashtext(concat_ws('|', "id"::text) || '')::bigint) % 1000000 < 10000`,
+		},
+		{
+			[]string{"tenant", "code"}, 0.0000015, "ab'c",
+			`abs(hashtext(concat_ws('|', "tenant"::text, "code"::text) || 'ab''c')::bigint) % 1000000 < 2`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := sampleCondition(tt.keys, tt.rate, tt.salt, quote); got != tt.want {
+			t.Errorf("sampleCondition(%v, %v, %q) = %q, want %q", tt.keys, tt.rate, tt.salt, got, tt.want)
+		}
+	}
+}
+
+func TestAndConditions(t *testing.T) {
+	tests := []struct {
+		conditions []string
+		want       string
+	}{
+		{nil, ""},
+		{[]string{"", ""}, ""},
+		{[]string{"a = 1", "", "b = 2"}, "a = 1 AND b = 2"},
+	}
+
+	for _, tt := range tests {
+		if got := andConditions(tt.conditions...); got != tt.want {
+			t.Errorf("andConditions(%q) = %q, want %q", tt.conditions, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Maximum sheet name length allowed by Excel
+const maxSheetNameLength = 31
+
+// Characters Excel does not allow in sheet names, plus the apostrophe which
+// would need escaping in sheet references
+var invalidSheetNameChars = strings.NewReplacer(
+	":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_", "'", "_",
+)
+
+// Helper that hands out unique sheet names for a workbook. Excel compares
+// sheet names case-insensitively and limits them to 31 characters, so plain
+// truncation of "<table>_<suffix>" can make two sheets collide.
+type sheetNamer struct {
+	used map[string]bool
+}
+
+// Function to create a sheet namer with names that are already taken
+func newSheetNamer(reserved ...string) *sheetNamer {
+	n := &sheetNamer{used: make(map[string]bool)}
+	for _, name := range reserved {
+		n.used[strings.ToLower(name)] = true
+	}
+	return n
+}
+
+// Function to get a unique sheet name for a table's detail sheet. The suffix
+// is always kept; the table part is shortened and numbered when needed.
+func (n *sheetNamer) name(tableName, suffix string) string {
+	base := invalidSheetNameChars.Replace(tableName)
+	if base == "" {
+		base = "table"
+	}
+	suffix = "_" + suffix
+
+	for attempt := 1; ; attempt++ {
+		marker := ""
+		if attempt > 1 {
+			marker = fmt.Sprintf("~%d", attempt)
+		}
+
+		room := maxSheetNameLength - len([]rune(suffix)) - len(marker)
+		tablePart := []rune(base)
+		if len(tablePart) > room {
+			tablePart = tablePart[:room]
+		}
+
+		candidate := string(tablePart) + marker + suffix
+		if !n.used[strings.ToLower(candidate)] {
+			n.used[strings.ToLower(candidate)] = true
+			return candidate
+		}
+	}
+}
+
+// Helper function to build an in-workbook hyperlink target for a cell
+func sheetLocation(sheet, cell string) string {
+	return fmt.Sprintf("'%s'!%s", strings.ReplaceAll(sheet, "'", "''"), cell)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSheetNamer(t *testing.T) {
+	long := strings.Repeat("a", 40)
+
+	tests := []struct {
+		table, suffix, want string
+	}{
+		{"users", "Diff", "users_Diff"},
+		{"Users", "Diff", "Users~2_Diff"},
+		{"users", "Records", "users_Records"},
+		{"a/b:c", "Diff", "a_b_c_Diff"},
+		{"", "Diff", "table_Diff"},
+		{long, "Diff", strings.Repeat("a", 26) + "_Diff"},
+		{long + "b", "Diff", strings.Repeat("a", 24) + "~2_Diff"},
+		{long + "c", "Diff", strings.Repeat("a", 24) + "~3_Diff"},
+		{"summary", "Stats", "summary~2_Stats"},
+	}
+
+	namer := newSheetNamer("Summary", "summary_Stats")
+	for _, tt := range tests {
+		got := namer.name(tt.table, tt.suffix)
+		if got != tt.want {
+			t.Errorf("name(%q, %q) = %q, want %q", tt.table, tt.suffix, got, tt.want)
+		}
+		if utf8.RuneCountInString(got) > maxSheetNameLength {
+			t.Errorf("name(%q, %q) = %q is longer than %d characters", tt.table, tt.suffix, got, maxSheetNameLength)
+		}
+	}
+}
+
+func TestSheetLocation(t *testing.T) {
+	tests := []struct{ sheet, cell, want string }{
+		{"users_Diff", "A1", "'users_Diff'!A1"},
+		{"it's", "B2", "'it''s'!B2"},
+	}
+
+	for _, tt := range tests {
+		if got := sheetLocation(tt.sheet, tt.cell); got != tt.want {
+			t.Errorf("sheetLocation(%q, %q) = %q, want %q", tt.sheet, tt.cell, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// Reads of one side of the comparison. With a consistent snapshot every read
+// goes through a single REPEATABLE READ, READ ONLY transaction, so counts and
+// data of all tables reflect the same point in time. Parallel workers join the
+// same snapshot through pg_export_snapshot.
+type snapshotReader struct {
+	db         *gorm.DB // nil for a side backed by a master-data file
+	tx         *gorm.DB // transaction holding the snapshot, nil when not consistent
+	snapshotID string   // exported snapshot, only set for parallel workers
+}
+
+// Function to start reading one side, optionally inside a snapshot transaction.
+// The snapshot is exported when it needs to be shared with parallel workers.
+func openSnapshotReader(db *gorm.DB, consistent, shared bool) (*snapshotReader, error) {
+	r := &snapshotReader{db: db}
+	if db == nil || !consistent {
+		return r, nil
+	}
+
+	tx, err := beginSnapshotTx(db, "")
+	if err != nil {
+		return nil, err
+	}
+	r.tx = tx
+
+	if shared {
+		if err := tx.Raw("SELECT pg_export_snapshot()").Scan(&r.snapshotID).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to export snapshot: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// Function to begin a REPEATABLE READ, READ ONLY transaction, importing an
+// exported snapshot when snapshotID is set
+func beginSnapshotTx(db *gorm.DB, snapshotID string) (*gorm.DB, error) {
+	tx := db.Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if tx.Error != nil {
+		return nil, fmt.Errorf("failed to begin snapshot transaction: %w", tx.Error)
+	}
+
+	if snapshotID != "" {
+		// Must be the first statement of the transaction; the ID can't be a parameter
+		if err := tx.Exec(fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", snapshotID)).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to import snapshot %s: %w", snapshotID, err)
+		}
+	}
+
+	return tx, nil
+}
+
+// Function to get the connection for reads on the main goroutine
+func (r *snapshotReader) conn() *gorm.DB {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// Function to get the connection for a comparison worker. The first worker
+// uses the snapshot transaction itself; other workers open their own
+// transaction on the exported snapshot. The returned function ends it.
+func (r *snapshotReader) worker(id int) (*gorm.DB, func(), error) {
+	if r.tx == nil || id == 0 {
+		return r.conn(), func() {}, nil
+	}
+
+	tx, err := beginSnapshotTx(r.db, r.snapshotID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, func() { tx.Rollback() }, nil
+}
+
+// Name of the savepoint set around the reads of each table
+const tableSavepoint = "compare_table"
+
+// Function to set a savepoint on a worker connection before a table is read.
+// A failed query (missing column, permission denied, bad cast) aborts the
+// whole transaction; rolling back to the savepoint keeps the snapshot usable
+// for the next tables. The returned function ends the savepoint, rolling back
+// when the table failed. Without a snapshot each query stands alone and
+// nothing is done.
+func (r *snapshotReader) savepoint(conn *gorm.DB) (func(failed bool), error) {
+	if r.tx == nil {
+		return func(bool) {}, nil
+	}
+
+	if err := conn.SavePoint(tableSavepoint).Error; err != nil {
+		return nil, fmt.Errorf("failed to set savepoint: %w", err)
+	}
+	return func(failed bool) {
+		var err error
+		if failed {
+			err = conn.RollbackTo(tableSavepoint).Error
+		} else {
+			err = conn.Exec("RELEASE SAVEPOINT " + tableSavepoint).Error
+		}
+		if err != nil {
+			log.Printf("Warning: failed to end savepoint: %v", err)
+		}
+	}, nil
+}
+
+// Function to run reads on connections of both sides inside savepoints, so a
+// failure doesn't abort the snapshot transactions for the reads after it
+func withSavepoints(devReader, stagingReader *snapshotReader, devConn, stagingConn *gorm.DB, read func() error) error {
+	devEnd, err := devReader.savepoint(devConn)
+	if err != nil {
+		return err
+	}
+	// With -same-database both sides can share one connection
+	stagingEnd := func(bool) {}
+	if stagingConn != devConn {
+		if stagingEnd, err = stagingReader.savepoint(stagingConn); err != nil {
+			devEnd(true)
+			return err
+		}
+	}
+
+	err = read()
+	stagingEnd(err != nil)
+	devEnd(err != nil)
+	return err
+}
+
+// Function to end the snapshot transaction. Nothing was written, so it is
+// rolled back.
+func (r *snapshotReader) close() {
+	if r.tx != nil {
+		r.tx.Rollback()
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// How soft-deleted rows are treated
+const (
+	softDeleteOff     = "off"     // compared like any other row
+	softDeleteExclude = "exclude" // left out of the comparison
+	softDeleteReport  = "report"  // rows deleted on one side only are reported separately
+)
+
+// Soft-delete columns from the config file. A row is soft-deleted when one
+// of its flag columns is true (or a non-zero integer) or one of its timestamp
+// columns is set.
+type SoftDeleteConfig struct {
+	Mode             string   `yaml:"mode"`              // off, exclude or report (-soft-delete overrides it)
+	FlagColumns      []string `yaml:"flag_columns"`      // boolean or integer columns, true or non-zero when deleted (default is_deleted)
+	TimestampColumns []string `yaml:"timestamp_columns"` // columns set when deleted (default deleted_at)
+}
+
+// Function to build the soft-delete settings from the config and the
+// -soft-delete flag. Returns nil when soft deletes are not treated specially.
+func newSoftDeleteConfig(config *SoftDeleteConfig, mode string) (*SoftDeleteConfig, error) {
+	resolved := SoftDeleteConfig{}
+	if config != nil {
+		resolved = *config
+	}
+	if mode != "" {
+		resolved.Mode = mode
+	}
+
+	switch resolved.Mode {
+	case "", softDeleteOff:
+		return nil, nil
+	case softDeleteExclude, softDeleteReport:
+	default:
+		return nil, fmt.Errorf("invalid soft-delete mode %q: expected '%s', '%s' or '%s'", resolved.Mode, softDeleteOff, softDeleteExclude, softDeleteReport)
+	}
+
+	if len(resolved.FlagColumns) == 0 && len(resolved.TimestampColumns) == 0 {
+		resolved.FlagColumns = []string{"is_deleted"}
+		resolved.TimestampColumns = []string{"deleted_at"}
+	}
+	return &resolved, nil
+}
+
+// Function to check whether soft-deleted rows are left out of the comparison
+func (c *SoftDeleteConfig) excludes() bool {
+	return c != nil && c.Mode == softDeleteExclude
+}
+
+// Function to check whether rows deleted on one side only are reported separately
+func (c *SoftDeleteConfig) reports() bool {
+	return c != nil && c.Mode == softDeleteReport
+}
+
+// Function to check whether a row is soft-deleted. Values from master-data
+// files are accepted as text too.
+func (c *SoftDeleteConfig) deleted(row map[string]interface{}) bool {
+	if c == nil {
+		return false
+	}
+
+	for _, col := range c.FlagColumns {
+		switch v := row[col].(type) {
+		case bool:
+			if v {
+				return true
+			}
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "t", "1", "yes", "y":
+				return true
+			}
+		default:
+			// Integer flags of any size, as read by the driver
+			rv := reflect.ValueOf(v)
+			switch rv.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				if rv.Int() != 0 {
+					return true
+				}
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				if rv.Uint() != 0 {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, col := range c.TimestampColumns {
+		if val, ok := row[col]; ok && val != nil {
+			if s, isString := val.(string); isString && strings.TrimSpace(s) == "" {
+				continue
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// Function to build the SQL condition selecting live rows, for the
+// soft-delete columns among the given ones. columnName translates a column to
+// its name in the queried table. Returns "" when the table has none of them.
+// Flag columns must be boolean or integer, since other types can't be tested
+// for "deleted" in SQL.
+func (c *SoftDeleteConfig) liveCondition(columns []string, columnTypes map[string]string, columnName func(string) string) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+
+	var conditions []string
+	for _, col := range c.FlagColumns {
+		if !containsString(columns, col) {
+			continue
+		}
+		switch dataType := strings.ToLower(columnTypes[col]); dataType {
+		case "boolean":
+			conditions = append(conditions, fmt.Sprintf("NOT COALESCE(%s, false)", columnName(col)))
+		case "smallint", "integer", "bigint":
+			conditions = append(conditions, fmt.Sprintf("COALESCE(%s, 0) = 0", columnName(col)))
+		default:
+			return "", fmt.Errorf("soft-delete flag column %s has type %s; expected boolean or an integer type", col, dataType)
+		}
+	}
+	for _, col := range c.TimestampColumns {
+		if containsString(columns, col) {
+			conditions = append(conditions, fmt.Sprintf("%s IS NULL", columnName(col)))
+		}
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// Function to check whether a column is one of the soft-delete columns
+func (c *SoftDeleteConfig) isColumn(col string) bool {
+	return c != nil && (containsString(c.FlagColumns, col) || containsString(c.TimestampColumns, col))
+}
+
+// Function to drop the soft-deleted rows read from a master-data file
+func (c *SoftDeleteConfig) liveRows(rows []map[string]interface{}) []map[string]interface{} {
+	var live []map[string]interface{}
+	for _, row := range rows {
+		if !c.deleted(row) {
+			live = append(live, row)
+		}
+	}
+	return live
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSoftDeleteDeleted(t *testing.T) {
+	c := &SoftDeleteConfig{Mode: softDeleteReport, FlagColumns: []string{"is_deleted"}, TimestampColumns: []string{"deleted_at"}}
+
+	tests := []struct {
+		name string
+		row  map[string]interface{}
+		want bool
+	}{
+		{"live", map[string]interface{}{"is_deleted": false, "deleted_at": nil}, false},
+		{"flag true", map[string]interface{}{"is_deleted": true}, true},
+		{"flag text", map[string]interface{}{"is_deleted": " Yes "}, true},
+		{"flag text false", map[string]interface{}{"is_deleted": "f"}, false},
+		{"int64", map[string]interface{}{"is_deleted": int64(1)}, true},
+		{"int64 zero", map[string]interface{}{"is_deleted": int64(0)}, false},
+		{"int32", map[string]interface{}{"is_deleted": int32(1)}, true},
+		{"int16", map[string]interface{}{"is_deleted": int16(2)}, true},
+		{"int8", map[string]interface{}{"is_deleted": int8(-1)}, true},
+		{"int", map[string]interface{}{"is_deleted": 1}, true},
+		{"uint8", map[string]interface{}{"is_deleted": uint8(1)}, true},
+		{"int zero", map[string]interface{}{"is_deleted": 0}, false},
+		{"timestamp", map[string]interface{}{"deleted_at": time.Now()}, true},
+		{"timestamp text", map[string]interface{}{"deleted_at": "2024-01-02"}, true},
+		{"empty timestamp text", map[string]interface{}{"deleted_at": "  "}, false},
+		{"no columns", map[string]interface{}{"id": 1}, false},
+	}
+
+	for _, tt := range tests {
+		if got := c.deleted(tt.row); got != tt.want {
+			t.Errorf("%s: deleted(%v) = %t, want %t", tt.name, tt.row, got, tt.want)
+		}
+	}
+
+	var off *SoftDeleteConfig
+	if off.deleted(map[string]interface{}{"is_deleted": true}) {
+		t.Error("deleted() without soft-delete settings = true, want false")
+	}
+}
+
+func TestSoftDeleteLiveCondition(t *testing.T) {
+	c := &SoftDeleteConfig{Mode: softDeleteExclude, FlagColumns: []string{"is_deleted"}, TimestampColumns: []string{"deleted_at"}}
+	same := func(col string) string { return col }
+
+	tests := []struct {
+		name    string
+		columns []string
+		types   map[string]string
+		rename  func(string) string
+		want    string
+		wantErr string
+	}{
+		{"boolean flag", []string{"id", "is_deleted"}, map[string]string{"is_deleted": "boolean"}, same, "NOT COALESCE(is_deleted, false)", ""},
+		{"integer flag", []string{"is_deleted"}, map[string]string{"is_deleted": "smallint"}, same, "COALESCE(is_deleted, 0) = 0", ""},
+		{"both", []string{"is_deleted", "deleted_at"}, map[string]string{"is_deleted": "boolean", "deleted_at": "timestamp with time zone"}, same,
+			"NOT COALESCE(is_deleted, false) AND deleted_at IS NULL", ""},
+		{"renamed", []string{"deleted_at"}, map[string]string{"deleted_at": "timestamp without time zone"}, func(col string) string { return "removed_at" },
+			"removed_at IS NULL", ""},
+		{"none", []string{"id", "name"}, map[string]string{}, same, "", ""},
+		{"text flag", []string{"is_deleted"}, map[string]string{"is_deleted": "character varying"}, same, "", "expected boolean or an integer type"},
+	}
+
+	for _, tt := range tests {
+		got, err := c.liveCondition(tt.columns, tt.types, tt.rename)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: liveCondition error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: liveCondition = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewSoftDeleteConfig(t *testing.T) {
+	tests := []struct {
+		config  *SoftDeleteConfig
+		mode    string
+		want    string // mode of the result, "" for nil
+		wantErr bool
+	}{
+		{nil, "", "", false},
+		{nil, "off", "", false},
+		{nil, "exclude", softDeleteExclude, false},
+		{&SoftDeleteConfig{Mode: "report"}, "", softDeleteReport, false},
+		{&SoftDeleteConfig{Mode: "report"}, "off", "", false},
+		{nil, "hide", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := newSoftDeleteConfig(tt.config, tt.mode)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newSoftDeleteConfig(%v, %q) error = %v, want error %t", tt.config, tt.mode, err, tt.wantErr)
+			continue
+		}
+		mode := ""
+		if got != nil {
+			mode = got.Mode
+			if len(got.FlagColumns) == 0 {
+				t.Errorf("newSoftDeleteConfig(%v, %q) has no default flag columns", tt.config, tt.mode)
+			}
+		}
+		if mode != tt.want {
+			t.Errorf("newSoftDeleteConfig(%v, %q) mode = %q, want %q", tt.config, tt.mode, mode, tt.want)
+		}
+	}
+}
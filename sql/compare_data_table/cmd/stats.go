@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Number of value transitions kept per column in the column statistics
+const maxTransitionsPerColumn = 5
+
+// Longest value shown in a transition before it is shortened
+const maxTransitionValueLength = 40
+
+// A dev -> staging value change and how often it occurs
+type valueTransition struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+func (t valueTransition) String() string {
+	return fmt.Sprintf("%s→%s ×%d", t.From, t.To, t.Count)
+}
+
+// Function to compute per-column drift statistics for a compared table.
+// Only columns with at least one differing row are returned, the most
+// drifted columns first.
+func computeColumnStats(columns []string, devData, stagingData, differences []map[string]interface{}, matchedRows int) []map[string]interface{} {
+	rowsDiffering := make(map[string]int)
+	transitions := make(map[string]map[valueTransition]int)
+
+	for _, diff := range differences {
+		col, _ := diff["column"].(string)
+		rowsDiffering[col]++
+
+		transition := valueTransition{
+			From: transitionValue(diff["dev_value"]),
+			To:   transitionValue(diff["staging_value"]),
+		}
+		if transitions[col] == nil {
+			transitions[col] = make(map[valueTransition]int)
+		}
+		transitions[col][transition]++
+	}
+
+	var stats []map[string]interface{}
+	for _, col := range columns {
+		if rowsDiffering[col] == 0 {
+			continue
+		}
+
+		// Most common transitions first
+		var top []valueTransition
+		for transition, count := range transitions[col] {
+			transition.Count = count
+			top = append(top, transition)
+		}
+		sort.Slice(top, func(i, j int) bool {
+			if top[i].Count != top[j].Count {
+				return top[i].Count > top[j].Count
+			}
+			return top[i].String() < top[j].String()
+		})
+		if len(top) > maxTransitionsPerColumn {
+			top = top[:maxTransitionsPerColumn]
+		}
+
+		var differingPct float64
+		if matchedRows > 0 {
+			differingPct = float64(rowsDiffering[col]) / float64(matchedRows) * 100
+		}
+
+		stats = append(stats, map[string]interface{}{
+			"column":           col,
+			"rows_differing":   rowsDiffering[col],
+			"differing_pct":    differingPct,
+			"distinct_dev":     countDistinct(devData, col),
+			"distinct_staging": countDistinct(stagingData, col),
+			"top_transitions":  top,
+		})
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i]["rows_differing"].(int) > stats[j]["rows_differing"].(int)
+	})
+
+	return stats
+}
+
+// Helper function to count the distinct values of a column
+func countDistinct(data []map[string]interface{}, col string) int {
+	seen := make(map[string]bool)
+	for _, row := range data {
+		seen[fmt.Sprintf("%v", row[col])] = true
+	}
+	return len(seen)
+}
+
+// Helper function to format a value for a transition, shortening long values
+func transitionValue(val interface{}) string {
+	var s string
+	switch v := val.(type) {
+	case nil:
+		s = "NULL"
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) > maxTransitionValueLength {
+		s = string([]rune(s)[:maxTransitionValueLength-1]) + "…"
+	}
+	return s
+}
+
+// Helper function to format the top transitions of a column for the report
+func formatTransitions(transitions []valueTransition) string {
+	parts := make([]string, len(transitions))
+	for i, t := range transitions {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, "; ")
+}
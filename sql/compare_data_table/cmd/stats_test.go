@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComputeColumnStats(t *testing.T) {
+	devData := []map[string]interface{}{
+		{"id": 1, "status": "new", "name": "a"},
+		{"id": 2, "status": "new", "name": "b"},
+		{"id": 3, "status": "done", "name": "c"},
+	}
+	stagingData := []map[string]interface{}{
+		{"id": 1, "status": "open", "name": "a"},
+		{"id": 2, "status": "open", "name": "b"},
+		{"id": 3, "status": "done", "name": nil},
+	}
+	differences := []map[string]interface{}{
+		{"column": "status", "dev_value": "new", "staging_value": "open"},
+		{"column": "status", "dev_value": "new", "staging_value": "open"},
+		{"column": "name", "dev_value": "c", "staging_value": nil},
+	}
+
+	stats := computeColumnStats([]string{"id", "name", "status"}, devData, stagingData, differences, 4)
+
+	want := []map[string]interface{}{
+		{
+			"column":           "status",
+			"rows_differing":   2,
+			"differing_pct":    50.0,
+			"distinct_dev":     2,
+			"distinct_staging": 2,
+			"top_transitions":  []valueTransition{{From: "new", To: "open", Count: 2}},
+		},
+		{
+			"column":           "name",
+			"rows_differing":   1,
+			"differing_pct":    25.0,
+			"distinct_dev":     3,
+			"distinct_staging": 3,
+			"top_transitions":  []valueTransition{{From: "c", To: "NULL", Count: 1}},
+		},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("computeColumnStats = %v, want %v", stats, want)
+	}
+}
+
+func TestComputeColumnStatsKeepsTopTransitions(t *testing.T) {
+	var differences []map[string]interface{}
+	for _, from := range []string{"a", "a", "a", "b", "b", "c", "d", "e", "f", "g"} {
+		differences = append(differences, map[string]interface{}{"column": "v", "dev_value": from, "staging_value": 0})
+	}
+
+	stats := computeColumnStats([]string{"v"}, nil, nil, differences, 0)
+	if len(stats) != 1 {
+		t.Fatalf("got %d columns, want 1", len(stats))
+	}
+
+	top := stats[0]["top_transitions"].([]valueTransition)
+	if len(top) != maxTransitionsPerColumn {
+		t.Fatalf("got %d transitions, want %d", len(top), maxTransitionsPerColumn)
+	}
+	if got := formatTransitions(top); got != "a→0 ×3; b→0 ×2; c→0 ×1; d→0 ×1; e→0 ×1" {
+		t.Errorf("formatTransitions = %q", got)
+	}
+	if pct := stats[0]["differing_pct"].(float64); pct != 0 {
+		t.Errorf("differing_pct without matched rows = %v, want 0", pct)
+	}
+}
+
+func TestTransitionValue(t *testing.T) {
+	long := strings.Repeat("x", maxTransitionValueLength+5)
+
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, "NULL"},
+		{[]byte("bytes"), "bytes"},
+		{12.5, "12.5"},
+		{"  two\n lines ", "two lines"},
+		{long, strings.Repeat("x", maxTransitionValueLength-1) + "…"},
+	}
+
+	for _, tt := range tests {
+		if got := transitionValue(tt.in); got != tt.want {
+			t.Errorf("transitionValue(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
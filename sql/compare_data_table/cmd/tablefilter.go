@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// A table name pattern from -include/-exclude. Patterns are globs ("ref_*",
+// "m_?", "[a-c]*") unless they start with "re:", in which case the rest is a
+// regular expression ("re:^ref_[a-z]+$"). A glob without wildcards matches
+// one table exactly.
+type tablePattern struct {
+	text  string
+	regex *regexp.Regexp // nil for a glob
+}
+
+// Function to parse a glob or "re:" regex table pattern
+func parseTablePattern(text string) (tablePattern, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return tablePattern{}, fmt.Errorf("empty table pattern")
+	}
+
+	if expr, ok := strings.CutPrefix(text, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return tablePattern{}, fmt.Errorf("invalid table regex %q: %w", expr, err)
+		}
+		return tablePattern{text: text, regex: re}, nil
+	}
+
+	if _, err := path.Match(text, ""); err != nil {
+		return tablePattern{}, fmt.Errorf("invalid table glob %q: %w", text, err)
+	}
+	return tablePattern{text: text}, nil
+}
+
+func (p tablePattern) match(tableName string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(tableName)
+	}
+	matched, _ := path.Match(p.text, tableName)
+	return matched
+}
+
+// Repeatable flag collecting table patterns. Each use may also hold several
+// comma-separated patterns.
+type tablePatternFlag []tablePattern
+
+func (f *tablePatternFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	texts := make([]string, len(*f))
+	for i, p := range *f {
+		texts[i] = p.text
+	}
+	return strings.Join(texts, ",")
+}
+
+func (f *tablePatternFlag) Set(value string) error {
+	for _, text := range strings.Split(value, ",") {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		p, err := parseTablePattern(text)
+		if err != nil {
+			return err
+		}
+		*f = append(*f, p)
+	}
+	return nil
+}
+
+// Function to keep the tables matching at least one include pattern (all
+// tables when there are none) and no exclude pattern
+func includeExcludeTables(tables []string, include, exclude tablePatternFlag) []string {
+	var filtered []string
+	for _, table := range tables {
+		if len(include) > 0 && !matchesAnyTablePattern(table, include) {
+			continue
+		}
+		if matchesAnyTablePattern(table, exclude) {
+			continue
+		}
+		filtered = append(filtered, table)
+	}
+	return filtered
+}
+
+func matchesAnyTablePattern(tableName string, patterns tablePatternFlag) bool {
+	for _, p := range patterns {
+		if p.match(tableName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTablePatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern, table string
+		want           bool
+	}{
+		{"users", "users", true},
+		{"users", "users_archive", false},
+		{"ref_*", "ref_country", true},
+		{"ref_*", "country_ref", false},
+		{"m_?", "m_a", true},
+		{"m_?", "m_ab", false},
+		{"[a-c]*", "books", true},
+		{"[a-c]*", "orders", false},
+		{" re:^ref_[a-z]+$ ", "ref_country", true},
+		{"re:^ref_[a-z]+$", "ref_2024", false},
+		{"re:log", "audit_log_2024", true},
+	}
+
+	for _, tt := range tests {
+		p, err := parseTablePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseTablePattern(%q): %v", tt.pattern, err)
+		}
+		if got := p.match(tt.table); got != tt.want {
+			t.Errorf("%q.match(%q) = %t, want %t", tt.pattern, tt.table, got, tt.want)
+		}
+	}
+}
+
+func TestParseTablePatternErrors(t *testing.T) {
+	for _, text := range []string{"", "  ", "re:(", "[a-"} {
+		if _, err := parseTablePattern(text); err == nil {
+			t.Errorf("parseTablePattern(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func TestTablePatternFlag(t *testing.T) {
+	var f tablePatternFlag
+	for _, value := range []string{"ref_*, m_?", "re:^log_", ",users,"} {
+		if err := f.Set(value); err != nil {
+			t.Fatalf("Set(%q): %v", value, err)
+		}
+	}
+	if got, want := f.String(), "ref_*,m_?,re:^log_,users"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if err := f.Set("ok,re:("); err == nil {
+		t.Error("Set with an invalid regex succeeded")
+	}
+}
+
+func TestIncludeExcludeTables(t *testing.T) {
+	tables := []string{"users", "orders", "ref_country", "ref_currency", "log_2024", "log_2025"}
+
+	patterns := func(values ...string) tablePatternFlag {
+		var f tablePatternFlag
+		for _, value := range values {
+			if err := f.Set(value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return f
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude tablePatternFlag
+		want             []string
+	}{
+		{"no filters", nil, nil, tables},
+		{"include glob", patterns("ref_*"), nil, []string{"ref_country", "ref_currency"}},
+		{"include several", patterns("users", "re:^log_"), nil, []string{"users", "log_2024", "log_2025"}},
+		{"exclude only", nil, patterns("log_*,orders"), []string{"users", "ref_country", "ref_currency"}},
+		{"exclude wins", patterns("ref_*"), patterns("*currency"), []string{"ref_country"}},
+		{"nothing left", patterns("missing"), nil, nil},
+	}
+
+	for _, tt := range tests {
+		if got := includeExcludeTables(tables, tt.include, tt.exclude); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: includeExcludeTables = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
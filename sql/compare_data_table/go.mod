@@ -3,8 +3,10 @@ module github.com/Alwanly/script-tools/sql/compare_data_table
 go 1.21
 
 require (
+	github.com/Alwanly/script-tools/sql/dbconfig v0.0.0
 	github.com/joho/godotenv v1.5.1
 	github.com/xuri/excelize/v2 v2.8.0
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/postgres v1.5.4
 	gorm.io/gorm v1.25.5
 )
@@ -24,3 +26,5 @@ require (
 	golang.org/x/net v0.14.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 )
+
+replace github.com/Alwanly/script-tools/sql/dbconfig => ../dbconfig
@@ -16,7 +16,7 @@ if %ERRORLEVEL% NEQ 0 (
 
 REM Run the tool with all arguments passed to this script
 cd /d "%~dp0"
-go run cmd/main.go %*
+go run ./cmd %*
 
 if %ERRORLEVEL% NEQ 0 (
     echo.
@@ -0,0 +1,208 @@
+// Package dbconfig holds the PostgreSQL connection settings shared by the
+// script tools: environment variables, named environment profiles and the
+// connection string built from them.
+package dbconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Database connection settings. Empty fields fall back to the PG*
+// environment variables, pg_service.conf and ~/.pgpass.
+type Config struct {
+	URL         string `yaml:"url"`     // postgres:// URL or keyword/value connection string
+	Service     string `yaml:"service"` // service name from pg_service.conf
+	Host        string `yaml:"host"`
+	Port        string `yaml:"port"`
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"`
+	DBName      string `yaml:"dbname"`
+	SSLMode     string `yaml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert"` // client certificate
+	SSLKey      string `yaml:"sslkey"`  // client certificate key
+
+	Profile string `yaml:"-"` // named environment the settings were loaded from
+}
+
+// FromEnv reads the connection settings from the <prefix>DB_* environment
+// variables, for example DEV_DB_HOST for the prefix "DEV_"
+func FromEnv(prefix string) Config {
+	return Config{
+		URL:         os.Getenv(prefix + "DB_URL"),
+		Service:     os.Getenv(prefix + "DB_SERVICE"),
+		Host:        os.Getenv(prefix + "DB_HOST"),
+		Port:        os.Getenv(prefix + "DB_PORT"),
+		User:        os.Getenv(prefix + "DB_USER"),
+		Password:    os.Getenv(prefix + "DB_PASSWORD"),
+		DBName:      os.Getenv(prefix + "DB_NAME"),
+		SSLMode:     os.Getenv(prefix + "DB_SSLMODE"),
+		SSLRootCert: os.Getenv(prefix + "DB_SSLROOTCERT"),
+		SSLCert:     os.Getenv(prefix + "DB_SSLCERT"),
+		SSLKey:      os.Getenv(prefix + "DB_SSLKEY"),
+	}
+}
+
+// DSN builds the connection string for a database. Settings that are
+// left empty are omitted, so the driver falls back to the standard PG*
+// environment variables, the service file (pg_service.conf) and ~/.pgpass,
+// the same way psql does. Settings given next to a URL override the matching
+// part of it.
+func (c Config) DSN() (string, error) {
+	if isPostgresURL(c.URL) {
+		u, err := c.connectionURL()
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		if query.Get("TimeZone") == "" {
+			query.Set("TimeZone", "UTC")
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	// Keyword/value connection string; a URL setting in this form is used as the base
+	var parts []string
+	if c.URL != "" {
+		parts = append(parts, c.URL)
+	}
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+quoteDSNValue(value))
+		}
+	}
+
+	add("service", c.Service)
+	add("host", c.Host)
+	add("port", c.Port)
+	add("user", c.User)
+	add("password", c.Password)
+	add("dbname", c.DBName)
+	for _, option := range c.sslOptions() {
+		add(option[0], option[1])
+	}
+
+	// Keep the previous default user when nothing else names one
+	if c.URL == "" && c.User == "" && c.Service == "" && os.Getenv("PGUSER") == "" && os.Getenv("PGSERVICE") == "" {
+		add("user", "postgres")
+	}
+	add("TimeZone", "UTC")
+
+	return strings.Join(parts, " "), nil
+}
+
+// Helper function to check whether a connection string is in URL form
+func isPostgresURL(s string) bool {
+	return strings.HasPrefix(s, "postgres://") || strings.HasPrefix(s, "postgresql://")
+}
+
+// Helper function to get the TLS settings as connection options
+func (c Config) sslOptions() [][2]string {
+	return [][2]string{
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	}
+}
+
+// Function to parse the connection URL and apply the settings given next to
+// it, which take precedence over the URL like they do in the keyword/value
+// form
+func (c Config) connectionURL() (*url.URL, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		// The parse error repeats the URL, which may contain a password
+		return nil, fmt.Errorf("invalid database URL")
+	}
+
+	if c.Host != "" || c.Port != "" {
+		host, port := u.Hostname(), u.Port()
+		if c.Host != "" {
+			host = c.Host
+		}
+		if c.Port != "" {
+			port = c.Port
+		}
+		u.Host = host
+		if port != "" {
+			u.Host = net.JoinHostPort(host, port)
+		}
+	}
+	if c.User != "" || c.Password != "" {
+		user := u.User.Username()
+		password, hasPassword := u.User.Password()
+		if c.User != "" {
+			user = c.User
+		}
+		if c.Password != "" {
+			password, hasPassword = c.Password, true
+		}
+		u.User = url.User(user)
+		if hasPassword {
+			u.User = url.UserPassword(user, password)
+		}
+	}
+	if c.DBName != "" {
+		u.Path = "/" + c.DBName
+		u.RawPath = ""
+	}
+
+	query := u.Query()
+	if c.Service != "" {
+		query.Set("service", c.Service)
+	}
+	for _, option := range c.sslOptions() {
+		if option[1] != "" {
+			query.Set(option[0], option[1])
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u, nil
+}
+
+// Helper function to quote a value for a keyword/value connection string
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
+}
+
+// String describes a database without its password, for logs and reports
+func (c Config) String() string {
+	if c.Profile != "" {
+		return c.Profile + ": " + Config{URL: c.URL, Service: c.Service, Host: c.Host, Port: c.Port, DBName: c.DBName}.String()
+	}
+	if c.URL != "" {
+		if u, err := c.connectionURL(); err == nil && u.Scheme != "" {
+			return u.Redacted()
+		}
+		return "(connection string)"
+	}
+	if c.Service != "" {
+		return "service " + c.Service
+	}
+
+	host := valueOrEnv(c.Host, "PGHOST", "localhost")
+	port := valueOrEnv(c.Port, "PGPORT", "5432")
+	dbName := valueOrEnv(c.DBName, "PGDATABASE", "")
+	return fmt.Sprintf("%s:%s/%s", host, port, dbName)
+}
+
+// Helper function to get a setting, falling back to a PG* environment variable
+func valueOrEnv(value, envKey, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	if value, ok := os.LookupEnv(envKey); ok {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,133 @@
+package dbconfig
+
+import (
+	"os"
+	"testing"
+)
+
+// Helper function to clear PG* variables for the duration of a test
+func unsetPGEnv(t *testing.T) {
+	for _, key := range []string{"PGHOST", "PGPORT", "PGUSER", "PGDATABASE", "PGSERVICE"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestDSN(t *testing.T) {
+	unsetPGEnv(t)
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{
+			name:   "keyword values",
+			config: Config{Host: "db", Port: "5433", User: "app", Password: "it's secret", DBName: "main", SSLMode: "require"},
+			want:   `host=db port=5433 user=app password='it\'s secret' dbname=main sslmode=require TimeZone=UTC`,
+		},
+		{
+			name:   "default user",
+			config: Config{Host: "db"},
+			want:   "host=db user=postgres TimeZone=UTC",
+		},
+		{
+			name:   "service",
+			config: Config{Service: "staging", SSLRootCert: `C:\certs\root.crt`},
+			want:   `service=staging sslrootcert='C:\\certs\\root.crt' TimeZone=UTC`,
+		},
+		{
+			name:   "keyword string as base",
+			config: Config{URL: "host=db dbname=main", SSLMode: "verify-full"},
+			want:   "host=db dbname=main sslmode=verify-full TimeZone=UTC",
+		},
+		{
+			name:   "URL",
+			config: Config{URL: "postgres://app:pw@db:5432/main?sslmode=disable", SSLMode: "require"},
+			want:   "postgres://app:pw@db:5432/main?TimeZone=UTC&sslmode=require",
+		},
+		{
+			name:   "URL with settings next to it",
+			config: Config{URL: "postgres://app:pw@db:5432/main", Host: "replica", User: "ro", DBName: "other db"},
+			want:   "postgres://ro:pw@replica:5432/other%20db?TimeZone=UTC",
+		},
+		{
+			name:   "URL with password and port next to it",
+			config: Config{URL: "postgres://app@[::1]/main", Port: "5433", Password: "p@ss", Service: "main"},
+			want:   "postgres://app:p%40ss@[::1]:5433/main?TimeZone=UTC&service=main",
+		},
+		{
+			name:   "URL with time zone",
+			config: Config{URL: "postgresql://db/main?TimeZone=Asia%2FJakarta"},
+			want:   "postgresql://db/main?TimeZone=Asia%2FJakarta",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.config.DSN()
+		if err != nil {
+			t.Errorf("%s: DSN: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: DSN = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDSNUserFromEnvironment(t *testing.T) {
+	unsetPGEnv(t)
+	t.Setenv("PGUSER", "alice")
+
+	got, err := Config{Host: "db"}.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "host=db TimeZone=UTC"; got != want {
+		t.Errorf("DSN = %q, want %q", got, want)
+	}
+}
+
+func TestDSNInvalidURL(t *testing.T) {
+	_, err := Config{URL: "postgres://app:secret@db:bad port/main"}.DSN()
+	if err == nil {
+		t.Fatal("DSN of an invalid URL succeeded")
+	}
+	if got := err.Error(); got != "invalid database URL" {
+		t.Errorf("error %q should not repeat the URL", got)
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	unsetPGEnv(t)
+
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{Config{Host: "db", Port: "5433", DBName: "main", Password: "secret"}, "db:5433/main"},
+		{Config{}, "localhost:5432/"},
+		{Config{URL: "postgres://app:secret@db/main"}, "postgres://app:xxxxx@db/main"},
+		{Config{URL: "host=db password=secret"}, "(connection string)"},
+		{Config{Service: "staging"}, "service staging"},
+		{Config{URL: "postgres://app:secret@db/main", DBName: "other"}, "postgres://app:xxxxx@db/other"},
+		{Config{Profile: "prod", Host: "db", DBName: "main", Password: "secret"}, "prod: db:5432/main"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.config.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("DEV_DB_HOST", "db")
+	t.Setenv("DEV_DB_SSLMODE", "require")
+	t.Setenv("DB_HOST", "other")
+
+	want := Config{Host: "db", SSLMode: "require"}
+	if got := FromEnv("DEV_"); got != want {
+		t.Errorf("FromEnv(DEV_) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package dbconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Location of the environment profiles shared by the script tools, relative
+// to the home directory. SCRIPT_TOOLS_ENVIRONMENTS points to another file.
+const DefaultProfilesFile = ".script-tools/environments.yaml"
+
+// Named database environments shared by the script tools, for example:
+//
+//	environments:
+//	  dev:
+//	    host: localhost
+//	    dbname: app_dev
+//	  staging:
+//	    url: postgres://app@staging-db:5432/app
+//	    sslmode: verify-full
+type environmentProfiles struct {
+	Environments map[string]Config `yaml:"environments"`
+}
+
+// ProfilesPath returns the path of the profiles file
+func ProfilesPath() string {
+	if path := os.Getenv("SCRIPT_TOOLS_ENVIRONMENTS"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return DefaultProfilesFile
+	}
+	return filepath.Join(home, DefaultProfilesFile)
+}
+
+// LoadProfile loads the connection settings of a named environment
+func LoadProfile(path, name string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read environment profiles %s: %w", path, err)
+	}
+
+	var profiles environmentProfiles
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return Config{}, fmt.Errorf("failed to parse environment profiles %s: %w", path, err)
+	}
+
+	config, ok := profiles.Environments[name]
+	if !ok {
+		var names []string
+		for n := range profiles.Environments {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Config{}, fmt.Errorf("environment %q not found in %s (available: %s)", name, path, strings.Join(names, ", "))
+	}
+
+	config.Profile = name
+	return config, nil
+}
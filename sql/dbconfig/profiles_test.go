@@ -0,0 +1,60 @@
+package dbconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "environments.yaml")
+	profiles := `environments:
+  uat:
+    url: postgres://app@uat-db:5432/app
+    sslmode: verify-full
+  dev:
+    host: localhost
+    dbname: app_dev
+`
+	if err := os.WriteFile(path, []byte(profiles), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		want    Config
+		wantErr bool
+	}{
+		{"uat", Config{URL: "postgres://app@uat-db:5432/app", SSLMode: "verify-full", Profile: "uat"}, false},
+		{"dev", Config{Host: "localhost", DBName: "app_dev", Profile: "dev"}, false},
+		{"prod", Config{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := LoadProfile(path, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LoadProfile(%q) error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("LoadProfile(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := LoadProfile(filepath.Join(t.TempDir(), "none.yaml"), "dev"); err == nil {
+		t.Error("LoadProfile of a missing file succeeded")
+	}
+}
+
+func TestProfilesPath(t *testing.T) {
+	t.Setenv("SCRIPT_TOOLS_ENVIRONMENTS", "/etc/envs.yaml")
+	if got := ProfilesPath(); got != "/etc/envs.yaml" {
+		t.Errorf("ProfilesPath() = %q, want the SCRIPT_TOOLS_ENVIRONMENTS file", got)
+	}
+
+	t.Setenv("SCRIPT_TOOLS_ENVIRONMENTS", "")
+	t.Setenv("HOME", "/home/u")
+	if got, want := ProfilesPath(), filepath.Join("/home/u", DefaultProfilesFile); got != want {
+		t.Errorf("ProfilesPath() = %q, want %q", got, want)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/Alwanly/script-tools/sql/generate_missing_data
 go 1.20
 
 require (
+	github.com/Alwanly/script-tools/sql/dbconfig v0.0.0
 	github.com/gofrs/uuid v4.4.0+incompatible
 	github.com/joho/godotenv v1.5.1
 	gorm.io/driver/postgres v1.5.4
@@ -19,4 +20,8 @@ require (
 	golang.org/x/crypto v0.15.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/Alwanly/script-tools/sql/dbconfig => ../dbconfig
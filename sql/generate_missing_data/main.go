@@ -5,27 +5,25 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/Alwanly/script-tools/sql/dbconfig"
 	"github.com/gofrs/uuid"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// Database configuration struct
-type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-}
+// Database connection settings, shared with the other script tools
+type DBConfig = dbconfig.Config
 
 // Connect to database
 func connectDB(config DBConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		config.Host, config.User, config.Password, config.DBName, config.Port)
+	dsn, err := config.DSN()
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
@@ -54,12 +52,23 @@ type Permission struct {
 	UpdatedBy   string     `gorm:"column:updated_by"`
 }
 
-// loadConfig loads configuration from environment variables or .env file
+// loadConfig loads configuration from a named environment profile, or from
+// environment variables or .env file
 func loadConfig() (DBConfig, error) {
 	// Define flags
-	envFile := flag.String("env", ".env", "Path to the .env file")
+	envName := flag.String("env", "", "Named environment from the profiles file (e.g. 'uat'); default: DB_* variables")
+	envFile := flag.String("env-file", ".env", "Path to the .env file")
+	profilesFile := flag.String("profiles", "", "Environment profiles file (default: $SCRIPT_TOOLS_ENVIRONMENTS or ~/"+dbconfig.DefaultProfilesFile+")")
 	flag.Parse()
 
+	// -env used to take the path of the .env file; a value that looks like a
+	// path is still read as one, until -env-file has replaced it everywhere
+	if strings.ContainsAny(*envName, `/\`) || strings.HasSuffix(*envName, ".env") {
+		log.Printf("Warning: -env=%s as the path of the .env file is deprecated, use -env-file=%s", *envName, *envName)
+		*envFile = *envName
+		*envName = ""
+	}
+
 	// Load environment variables from .env file
 	err := godotenv.Load(*envFile)
 	if err != nil {
@@ -67,18 +76,21 @@ func loadConfig() (DBConfig, error) {
 		log.Println("Using environment variables instead")
 	}
 
-	// Get database connection details from environment variables
-	dbConfig := DBConfig{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASSWORD", ""),
-		DBName:   getEnv("DB_NAME", ""),
+	if *envName != "" {
+		path := *profilesFile
+		if path == "" {
+			path = dbconfig.ProfilesPath()
+		}
+		log.Printf("Using environment %s from %s", *envName, path)
+		return dbconfig.LoadProfile(path, *envName)
 	}
 
-	// Validate database name
-	if dbConfig.DBName == "" {
-		return dbConfig, fmt.Errorf("database name not provided. Set DB_NAME in .env file or environment variable")
+	// Get database connection details from environment variables
+	dbConfig := dbconfig.FromEnv("")
+
+	// Validate database name (a URL or service can name it too)
+	if dbConfig.DBName == "" && dbConfig.URL == "" && dbConfig.Service == "" && os.Getenv("PGDATABASE") == "" && os.Getenv("PGSERVICE") == "" {
+		return dbConfig, fmt.Errorf("database name not provided. Set DB_NAME, DB_URL or DB_SERVICE in .env file or environment variable")
 	}
 
 	return dbConfig, nil